@@ -0,0 +1,498 @@
+package config
+
+import "database/sql"
+
+// schema creates the tables, columns and indexes the repositories rely on,
+// in the order the features that use them were added. Every statement is
+// safe to run again on each start, so an existing database only picks up
+// what it is missing.
+var schema = []string{
+	// Products, categories and sales.
+	`CREATE TABLE IF NOT EXISTS categories (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS products (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		price INT NOT NULL,
+		stock INT NOT NULL DEFAULT 0,
+		category_id INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id SERIAL PRIMARY KEY,
+		total_amount INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS transaction_details (
+		id SERIAL PRIMARY KEY,
+		transaction_id INT NOT NULL REFERENCES transactions (id),
+		product_id INT NOT NULL,
+		quantity INT NOT NULL,
+		subtotal INT NOT NULL
+	)`,
+
+	// Held carts.
+	`CREATE TABLE IF NOT EXISTS carts (
+		id SERIAL PRIMARY KEY,
+		status TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		reserved_until TIMESTAMPTZ,
+		transaction_id INT REFERENCES transactions (id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS cart_items (
+		cart_id INT NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+		product_id INT NOT NULL,
+		quantity INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (cart_id, product_id)
+	)`,
+
+	// Stock reservations; the sweeper releases them once they expire.
+	`CREATE TABLE IF NOT EXISTS stock_reservations (
+		id SERIAL PRIMARY KEY,
+		product_id INT NOT NULL,
+		quantity INT NOT NULL,
+		reference TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMPTZ NOT NULL,
+		released_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE INDEX IF NOT EXISTS stock_reservations_active_idx ON stock_reservations (product_id) WHERE released_at IS NULL",
+
+	// Receipt numbers, counted per scope such as a store and day.
+	`CREATE TABLE IF NOT EXISTS receipt_sequences (
+		scope TEXT PRIMARY KEY,
+		last_value INT NOT NULL
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS receipt_number TEXT",
+	// Receipt numbers identify a sale on paper.
+	"CREATE UNIQUE INDEX IF NOT EXISTS transactions_receipt_number_key ON transactions (receipt_number)",
+
+	// Tenders and change printed on receipts.
+	`CREATE TABLE IF NOT EXISTS transaction_payments (
+		id SERIAL PRIMARY KEY,
+		transaction_id INT NOT NULL REFERENCES transactions (id),
+		method TEXT NOT NULL,
+		amount INT NOT NULL,
+		reference TEXT NOT NULL DEFAULT ''
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS paid_amount INT",
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS change_amount INT",
+
+	// Staff logins.
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		password_hash TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users (id),
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+
+	// Who rang up a sale, and where.
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS cashier_id INT REFERENCES users (id)",
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS register_id TEXT",
+
+	// Cashier shifts and the cash paid in and out during them.
+	`CREATE TABLE IF NOT EXISTS shifts (
+		id SERIAL PRIMARY KEY,
+		cashier_id INT NOT NULL REFERENCES users (id),
+		register_id TEXT,
+		status TEXT NOT NULL,
+		opening_cash INT NOT NULL DEFAULT 0,
+		expected_cash INT,
+		counted_cash INT,
+		variance INT,
+		note TEXT NOT NULL DEFAULT '',
+		opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		closed_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS shift_cash_movements (
+		id SERIAL PRIMARY KEY,
+		shift_id INT NOT NULL REFERENCES shifts (id),
+		type TEXT NOT NULL,
+		amount INT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_by INT NOT NULL REFERENCES users (id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts (id)",
+	// A cashier has one open shift at a time.
+	"CREATE UNIQUE INDEX IF NOT EXISTS shifts_open_cashier_key ON shifts (cashier_id) WHERE status = 'open'",
+
+	// Audit trail. actor_id has no foreign key so entries outlive the
+	// rows they mention.
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id SERIAL PRIMARY KEY,
+		actor_id INT,
+		actor_name TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id INT NOT NULL,
+		before JSONB,
+		after JSONB,
+		request_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id)",
+
+	// Customers.
+	`CREATE TABLE IF NOT EXISTS customers (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		notes TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers (id)",
+
+	// Loyalty points. loyalty_rules holds a single row with id 1.
+	`CREATE TABLE IF NOT EXISTS loyalty_rules (
+		id INT PRIMARY KEY,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		rupiah_per_point INT NOT NULL,
+		point_value INT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS loyalty_category_multipliers (
+		category_id INT PRIMARY KEY,
+		multiplier DOUBLE PRECISION NOT NULL
+	)`,
+	"ALTER TABLE customers ADD COLUMN IF NOT EXISTS loyalty_points INT NOT NULL DEFAULT 0",
+	`CREATE TABLE IF NOT EXISTS loyalty_ledger (
+		id SERIAL PRIMARY KEY,
+		customer_id INT NOT NULL REFERENCES customers (id),
+		transaction_id INT REFERENCES transactions (id),
+		type TEXT NOT NULL,
+		points INT NOT NULL,
+		balance_after INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ",
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS refunded_by INT REFERENCES users (id)",
+
+	// Gift cards and store credit.
+	`CREATE TABLE IF NOT EXISTS gift_cards (
+		id SERIAL PRIMARY KEY,
+		code TEXT NOT NULL UNIQUE,
+		initial_balance INT NOT NULL,
+		balance INT NOT NULL,
+		status TEXT NOT NULL,
+		customer_id INT REFERENCES customers (id),
+		expires_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS gift_card_ledger (
+		id SERIAL PRIMARY KEY,
+		gift_card_id INT NOT NULL REFERENCES gift_cards (id),
+		transaction_id INT REFERENCES transactions (id),
+		type TEXT NOT NULL,
+		amount INT NOT NULL,
+		balance_after INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+
+	// Product variants. Cart lines use variant_id 0 for plain products so
+	// the variant can be part of the line key; elsewhere it is NULL.
+	`CREATE TABLE IF NOT EXISTS product_variants (
+		id SERIAL PRIMARY KEY,
+		product_id INT NOT NULL,
+		sku TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		options JSONB NOT NULL DEFAULT '{}',
+		price INT NOT NULL,
+		stock INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE INDEX IF NOT EXISTS product_variants_product_idx ON product_variants (product_id)",
+	"ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS variant_id INT",
+	"ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS variant_id INT",
+	"ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS variant_id INT NOT NULL DEFAULT 0",
+	"ALTER TABLE cart_items DROP CONSTRAINT IF EXISTS cart_items_cart_id_product_id_key",
+
+	// Modifier groups. Cart lines keep the sorted, comma separated
+	// modifier ids so the same choice merges into one line.
+	`CREATE TABLE IF NOT EXISTS modifier_groups (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		required BOOLEAN NOT NULL DEFAULT FALSE,
+		min_select INT NOT NULL DEFAULT 0,
+		max_select INT NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS modifiers (
+		id SERIAL PRIMARY KEY,
+		group_id INT NOT NULL REFERENCES modifier_groups (id),
+		name TEXT NOT NULL,
+		price_delta INT NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS product_modifier_groups (
+		product_id INT NOT NULL,
+		group_id INT NOT NULL REFERENCES modifier_groups (id),
+		PRIMARY KEY (product_id, group_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS transaction_detail_modifiers (
+		id SERIAL PRIMARY KEY,
+		detail_id INT NOT NULL REFERENCES transaction_details (id),
+		modifier_id INT REFERENCES modifiers (id) ON DELETE SET NULL,
+		name TEXT NOT NULL,
+		price_delta INT NOT NULL
+	)`,
+	"ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS modifier_ids TEXT NOT NULL DEFAULT ''",
+	"CREATE UNIQUE INDEX IF NOT EXISTS cart_items_line_key ON cart_items (cart_id, product_id, variant_id, modifier_ids)",
+
+	// Composite products and the component stock each sale used.
+	"ALTER TABLE products ADD COLUMN IF NOT EXISTS cost_price INT NOT NULL DEFAULT 0",
+	`CREATE TABLE IF NOT EXISTS product_components (
+		product_id INT NOT NULL,
+		component_id INT NOT NULL,
+		quantity INT NOT NULL,
+		PRIMARY KEY (product_id, component_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS transaction_detail_components (
+		id SERIAL PRIMARY KEY,
+		detail_id INT NOT NULL REFERENCES transaction_details (id),
+		component_id INT NOT NULL,
+		quantity INT NOT NULL
+	)`,
+
+	// Goods receipts and the cost of each sale line.
+	`CREATE TABLE IF NOT EXISTS goods_receipts (
+		id SERIAL PRIMARY KEY,
+		reference TEXT NOT NULL DEFAULT '',
+		supplier TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		received_by INT REFERENCES users (id),
+		received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS goods_receipt_items (
+		id SERIAL PRIMARY KEY,
+		receipt_id INT NOT NULL REFERENCES goods_receipts (id),
+		product_id INT NOT NULL,
+		variant_id INT,
+		quantity INT NOT NULL,
+		cost_price INT NOT NULL
+	)`,
+	"ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS cost_price INT NOT NULL DEFAULT 0",
+	"ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS cost INT",
+
+	// Cost layers and the stock ledger they are consumed through.
+	`CREATE TABLE IF NOT EXISTS cost_layers (
+		id SERIAL PRIMARY KEY,
+		product_id INT NOT NULL,
+		variant_id INT,
+		quantity INT NOT NULL,
+		remaining INT NOT NULL,
+		unit_cost INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE INDEX IF NOT EXISTS cost_layers_open_idx ON cost_layers (product_id, COALESCE(variant_id, 0)) WHERE remaining > 0",
+	`CREATE TABLE IF NOT EXISTS stock_movements (
+		id SERIAL PRIMARY KEY,
+		product_id INT NOT NULL,
+		variant_id INT,
+		layer_id INT REFERENCES cost_layers (id),
+		movement_type TEXT NOT NULL,
+		quantity INT NOT NULL,
+		value INT NOT NULL,
+		reference_type TEXT,
+		reference_id INT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, COALESCE(variant_id, 0))",
+	"CREATE INDEX IF NOT EXISTS stock_movements_reference_idx ON stock_movements (reference_type, reference_id)",
+
+	// Stocktakes. A line is one product or variant of the session.
+	`CREATE TABLE IF NOT EXISTS stocktakes (
+		id SERIAL PRIMARY KEY,
+		status TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		category_id INT,
+		created_by INT REFERENCES users (id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		closed_by INT REFERENCES users (id),
+		closed_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS stocktake_lines (
+		id SERIAL PRIMARY KEY,
+		stocktake_id INT NOT NULL REFERENCES stocktakes (id),
+		product_id INT NOT NULL,
+		variant_id INT,
+		expected INT NOT NULL,
+		counted INT,
+		counted_at TIMESTAMPTZ,
+		variance_value INT
+	)`,
+	"CREATE UNIQUE INDEX IF NOT EXISTS stocktake_lines_line_key ON stocktake_lines (stocktake_id, product_id, COALESCE(variant_id, 0))",
+
+	// Suppliers, what they supply and purchase orders. supplier_products
+	// uses variant_id 0 for plain products so it can be part of the key.
+	`CREATE TABLE IF NOT EXISTS suppliers (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS supplier_products (
+		supplier_id INT NOT NULL REFERENCES suppliers (id),
+		product_id INT NOT NULL,
+		variant_id INT NOT NULL DEFAULT 0,
+		supplier_sku TEXT NOT NULL DEFAULT '',
+		last_cost INT NOT NULL DEFAULT 0,
+		min_stock INT NOT NULL DEFAULT 0,
+		max_stock INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (supplier_id, product_id, variant_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS purchase_orders (
+		id SERIAL PRIMARY KEY,
+		supplier_id INT NOT NULL REFERENCES suppliers (id),
+		status TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		created_by INT REFERENCES users (id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		sent_at TIMESTAMPTZ,
+		closed_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS purchase_order_items (
+		id SERIAL PRIMARY KEY,
+		purchase_order_id INT NOT NULL REFERENCES purchase_orders (id),
+		product_id INT NOT NULL,
+		variant_id INT,
+		quantity INT NOT NULL,
+		received_quantity INT NOT NULL DEFAULT 0,
+		unit_cost INT NOT NULL
+	)`,
+	"ALTER TABLE goods_receipts ADD COLUMN IF NOT EXISTS purchase_order_id INT REFERENCES purchase_orders (id)",
+	"ALTER TABLE goods_receipts ADD COLUMN IF NOT EXISTS supplier_id INT",
+
+	// Lots with expiry dates. variant_id is 0 rather than NULL so it can
+	// be part of the lot key, and lot_number is '' for stock received
+	// without one.
+	"ALTER TABLE products ADD COLUMN IF NOT EXISTS lot_tracked BOOLEAN NOT NULL DEFAULT FALSE",
+	`CREATE TABLE IF NOT EXISTS product_lots (
+		id SERIAL PRIMARY KEY,
+		product_id INT NOT NULL,
+		variant_id INT NOT NULL DEFAULT 0,
+		lot_number TEXT NOT NULL,
+		expires_at DATE,
+		quantity INT NOT NULL,
+		received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (product_id, variant_id, lot_number)
+	)`,
+	"ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS lot_number TEXT",
+	"ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS expires_at DATE",
+	`CREATE TABLE IF NOT EXISTS transaction_detail_lots (
+		id SERIAL PRIMARY KEY,
+		detail_id INT NOT NULL REFERENCES transaction_details (id),
+		product_id INT NOT NULL,
+		lot_id INT REFERENCES product_lots (id),
+		lot_number TEXT NOT NULL,
+		expires_at DATE,
+		quantity INT NOT NULL
+	)`,
+
+	// Stores. The default store keeps its stock in products and
+	// product_variants; store_stock holds the other stores' stock and
+	// every store's price overrides. Rows elsewhere leave store_id NULL
+	// for the default store.
+	`CREATE TABLE IF NOT EXISTS stores (
+		id SERIAL PRIMARY KEY,
+		code TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL DEFAULT '',
+		phone TEXT NOT NULL DEFAULT '',
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	"CREATE UNIQUE INDEX IF NOT EXISTS stores_default_key ON stores (is_default) WHERE is_default",
+	`CREATE TABLE IF NOT EXISTS store_stock (
+		store_id INT NOT NULL REFERENCES stores (id),
+		product_id INT NOT NULL,
+		variant_id INT NOT NULL DEFAULT 0,
+		stock INT NOT NULL DEFAULT 0,
+		price INT,
+		PRIMARY KEY (store_id, product_id, variant_id)
+	)`,
+	"ALTER TABLE transactions ADD COLUMN IF NOT EXISTS store_id INT REFERENCES stores (id)",
+	"ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS store_id INT",
+	"ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS store_id INT",
+	"ALTER TABLE goods_receipts ADD COLUMN IF NOT EXISTS store_id INT",
+	"ALTER TABLE stocktakes ADD COLUMN IF NOT EXISTS store_id INT",
+	// Staff work at a store; NULL is the default store.
+	"ALTER TABLE users ADD COLUMN IF NOT EXISTS store_id INT REFERENCES stores (id)",
+	// Lots belong to a store. Like variant_id, store_id is 0 rather than
+	// NULL for the default store so it can be part of the lot key.
+	"ALTER TABLE product_lots ADD COLUMN IF NOT EXISTS store_id INT NOT NULL DEFAULT 0",
+	"ALTER TABLE product_lots DROP CONSTRAINT IF EXISTS product_lots_product_id_variant_id_lot_number_key",
+	"CREATE UNIQUE INDEX IF NOT EXISTS product_lots_store_lot_key ON product_lots (product_id, variant_id, store_id, lot_number)",
+
+	// Transfers between stores.
+	`CREATE TABLE IF NOT EXISTS stock_transfers (
+		id SERIAL PRIMARY KEY,
+		from_store_id INT NOT NULL REFERENCES stores (id),
+		to_store_id INT NOT NULL REFERENCES stores (id),
+		status TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		requested_by INT REFERENCES users (id),
+		requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		dispatched_by INT REFERENCES users (id),
+		dispatched_at TIMESTAMPTZ,
+		received_by INT REFERENCES users (id),
+		received_at TIMESTAMPTZ,
+		received_note TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS stock_transfer_items (
+		id SERIAL PRIMARY KEY,
+		transfer_id INT NOT NULL REFERENCES stock_transfers (id),
+		product_id INT NOT NULL,
+		variant_id INT,
+		quantity INT NOT NULL,
+		dispatched_quantity INT NOT NULL DEFAULT 0,
+		received_quantity INT NOT NULL DEFAULT 0,
+		cost INT NOT NULL DEFAULT 0
+	)`,
+	// A product or variant is on a transfer once.
+	"CREATE UNIQUE INDEX IF NOT EXISTS stock_transfer_items_line_key ON stock_transfer_items (transfer_id, product_id, COALESCE(variant_id, 0))",
+	// Lots a transfer took out of its source store, to be booked into the
+	// destination store on receipt.
+	`CREATE TABLE IF NOT EXISTS stock_transfer_lots (
+		id SERIAL PRIMARY KEY,
+		transfer_id INT NOT NULL REFERENCES stock_transfers (id),
+		product_id INT NOT NULL,
+		variant_id INT NOT NULL DEFAULT 0,
+		lot_number TEXT NOT NULL,
+		expires_at DATE,
+		quantity INT NOT NULL
+	)`,
+}
+
+func EnsureSchema(db *sql.DB) error {
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ go 1.23.5
 require (
 	github.com/lib/pq v1.10.9
 	github.com/spf13/viper v1.21.0
+	golang.org/x/crypto v0.41.0
 )
 
 require (
@@ -18,6 +19,6 @@ require (
 	github.com/spf13/pflag v1.0.10 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
 )
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/middleware"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+)
+
+type AuditHandler struct {
+	service *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+func (h *AuditHandler) HandleAudit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/audit?entity_type=product&entity_id=&actor_id=&from=2026-01-01&to=2026-01-31&limit=
+func (h *AuditHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.AuditFilter{EntityType: q.Get("entity_type")}
+	var err error
+
+	for key, dest := range map[string]*int{"entity_id": &filter.EntityID, "actor_id": &filter.ActorID, "limit": &filter.Limit} {
+		if v := q.Get(key); v != "" {
+			*dest, err = strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Invalid "+key, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	// Dates are business days in the store's time zone.
+	if q.Get("from") != "" || q.Get("to") != "" {
+		filter.From, filter.To, err = parseBusinessDays(q, h.service.BusinessDay())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.service.GetAll(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// actorFrom identifies who is making a change, for the audit log.
+func actorFrom(r *http.Request) models.Actor {
+	actor := models.Actor{
+		RequestID: middleware.RequestIDFromContext(r.Context()),
+		StoreID:   middleware.StoreIDFromContext(r.Context()),
+	}
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		actor.UserID = claims.UserID
+		actor.Username = claims.Username
+	}
+	return actor
+}
+
+// storeFrom is the store a request acts for, 0 for the default store.
+func storeFrom(r *http.Request) int {
+	return middleware.StoreIDFromContext(r.Context())
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CartHandler struct {
+	service *services.CartService
+}
+
+func NewCartHandler(service *services.CartService) *CartHandler {
+	return &CartHandler{
+		service: service,
+	}
+}
+
+func (h *CartHandler) HandleCarts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CartHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	carts, err := h.service.GetAll(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(carts)
+}
+
+func (h *CartHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var cart models.Cart
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&cart)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	err := h.service.Create(&cart, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// /api/carts/{id}, /api/carts/{id}/items[/{product_id}[?variant_id=&modifier_ids=1,2]], /api/carts/{id}/{hold|resume|checkout}
+func (h *CartHandler) HandleCartByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/carts/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid cart ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.GetByID(w, r, id)
+		case http.MethodDelete:
+			h.Delete(w, r, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[1] == "items":
+		switch r.Method {
+		case http.MethodPost:
+			h.AddItem(w, r, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 3 && parts[1] == "items":
+		productID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+		line := models.CheckoutItem{ProductID: productID}
+		if v := r.URL.Query().Get("variant_id"); v != "" {
+			line.VariantID, err = strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Invalid variant_id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("modifier_ids"); v != "" {
+			for _, s := range strings.Split(v, ",") {
+				modifierID, err := strconv.Atoi(s)
+				if err != nil {
+					http.Error(w, "Invalid modifier_ids", http.StatusBadRequest)
+					return
+				}
+				line.ModifierIDs = append(line.ModifierIDs, modifierID)
+			}
+		}
+		switch r.Method {
+		case http.MethodPut:
+			h.UpdateItem(w, r, id, line)
+		case http.MethodDelete:
+			h.RemoveItem(w, r, id, line)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && r.Method != http.MethodPost:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case len(parts) == 2 && parts[1] == "hold":
+		h.Hold(w, r, id)
+	case len(parts) == 2 && parts[1] == "resume":
+		h.Resume(w, r, id)
+	case len(parts) == 2 && parts[1] == "checkout":
+		h.Checkout(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *CartHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	cart, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+func (h *CartHandler) Delete(w http.ResponseWriter, r *http.Request, id int) {
+	err := h.service.Delete(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Cart deleted successfully"})
+}
+
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request, id int) {
+	var item models.CheckoutItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.AddItem(id, item, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetByID(w, r, id)
+}
+
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request, id int, line models.CheckoutItem) {
+	var item models.CheckoutItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	item.ProductID = line.ProductID
+	item.VariantID = line.VariantID
+	item.ModifierIDs = line.ModifierIDs
+	err = h.service.UpdateItem(id, item, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetByID(w, r, id)
+}
+
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request, id int, line models.CheckoutItem) {
+	err := h.service.RemoveItem(id, line, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetByID(w, r, id)
+}
+
+func (h *CartHandler) Hold(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.HoldCartRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	req.StoreID = storeFrom(r)
+	err := h.service.Hold(id, req, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetByID(w, r, id)
+}
+
+func (h *CartHandler) Resume(w http.ResponseWriter, r *http.Request, id int) {
+	err := h.service.Resume(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetByID(w, r, id)
+}
+
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.CheckoutRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	setCheckoutContext(r, &req)
+	transaction, err := h.service.Checkout(id, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
@@ -47,7 +47,7 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Create(&category)
+	err = h.service.Create(&category, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -99,7 +99,7 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Update(id, &category)
+	err = h.service.Update(id, &category, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -115,7 +115,7 @@ func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid category ID", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Delete(id)
+	err = h.service.Delete(id, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"kasir-api/models"
+	"net/url"
+	"time"
+)
+
+// parseBusinessDays reads ?from=YYYY-MM-DD&to=YYYY-MM-DD as a half-open
+// range of business days: from the start of the from day to the start of
+// the day after to, in the store's time zone. to defaults to today and
+// from to today or, when to is earlier, to the to day.
+func parseBusinessDays(q url.Values, day models.BusinessDay) (time.Time, time.Time, error) {
+	to := day.Date(time.Now())
+	from := to
+
+	var err error
+	if v := q.Get("to"); v != "" {
+		to, err = time.ParseInLocation("2006-01-02", v, day.Location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid to date, use YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			from = to
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		from, err = time.ParseInLocation("2006-01-02", v, day.Location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid from date, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to date is before from date")
+	}
+
+	return day.Start(from), day.Start(to.AddDate(0, 0, 1)), nil
+}
+
+// parseAsOf reads ?as_of=YYYY-MM-DD as the end of that business day. It
+// defaults to now.
+func parseAsOf(q url.Values, day models.BusinessDay) (time.Time, error) {
+	v := q.Get("as_of")
+	if v == "" {
+		return time.Now(), nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", v, day.Location)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid as_of date, use YYYY-MM-DD")
+	}
+	return day.Start(date.AddDate(0, 0, 1)), nil
+}
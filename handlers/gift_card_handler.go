@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type GiftCardHandler struct {
+	service *services.GiftCardService
+}
+
+func NewGiftCardHandler(service *services.GiftCardService) *GiftCardHandler {
+	return &GiftCardHandler{
+		service: service,
+	}
+}
+
+func (h *GiftCardHandler) HandleGiftCards(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Issue(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *GiftCardHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	customerID := 0
+	if v := r.URL.Query().Get("customer_id"); v != "" {
+		var err error
+		customerID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid customer_id", http.StatusBadRequest)
+			return
+		}
+	}
+	cards, err := h.service.GetAll(customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cards)
+}
+
+func (h *GiftCardHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	var req models.IssueGiftCardRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	card, err := h.service.Issue(req, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+// /api/gift-cards/{code}
+func (h *GiftCardHandler) HandleGiftCardByCode(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimPrefix(r.URL.Path, "/api/gift-cards/")
+	if code == "" || strings.Contains(code, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.GetByCode(w, r, code)
+	case http.MethodDelete:
+		h.Void(w, r, code)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *GiftCardHandler) GetByCode(w http.ResponseWriter, r *http.Request, code string) {
+	card, err := h.service.GetByCode(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *GiftCardHandler) Void(w http.ResponseWriter, r *http.Request, code string) {
+	err := h.service.Void(code, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Gift card voided successfully"})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type GoodsReceiptHandler struct {
+	service *services.GoodsReceiptService
+}
+
+func NewGoodsReceiptHandler(service *services.GoodsReceiptService) *GoodsReceiptHandler {
+	return &GoodsReceiptHandler{
+		service: service,
+	}
+}
+
+func (h *GoodsReceiptHandler) HandleGoodsReceipts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/goods-receipts?from=2026-01-01&to=2026-01-31
+func (h *GoodsReceiptHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseBusinessDays(r.URL.Query(), h.service.BusinessDay())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	receipts, err := h.service.GetAll(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipts)
+}
+
+func (h *GoodsReceiptHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var receipt models.GoodsReceipt
+	err := json.NewDecoder(r.Body).Decode(&receipt)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.Create(&receipt, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+func (h *GoodsReceiptHandler) HandleGoodsReceiptByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/goods-receipts/"))
+	if err != nil {
+		http.Error(w, "Invalid goods receipt ID", http.StatusBadRequest)
+		return
+	}
+	receipt, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
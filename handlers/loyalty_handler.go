@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+)
+
+type LoyaltyHandler struct {
+	service *services.LoyaltyService
+}
+
+func NewLoyaltyHandler(service *services.LoyaltyService) *LoyaltyHandler {
+	return &LoyaltyHandler{
+		service: service,
+	}
+}
+
+// GET/PUT /api/loyalty/rules
+func (h *LoyaltyHandler) HandleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetRules(w, r)
+	case http.MethodPut:
+		h.UpdateRules(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *LoyaltyHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.service.GetRules()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+func (h *LoyaltyHandler) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	var rules models.LoyaltyRules
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.UpdateRules(&rules, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.GetRules(w, r)
+}
+
+func (h *LoyaltyHandler) GetAccount(w http.ResponseWriter, r *http.Request, customerID int) {
+	account, err := h.service.GetAccount(customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
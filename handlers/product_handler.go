@@ -32,7 +32,7 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
-	products, err := h.service.GetAll(name)
+	products, err := h.service.GetAll(name, storeFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -48,7 +48,7 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Create(&product)
+	err = h.service.Create(&product, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -58,7 +58,13 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(product)
 }
 
+// /api/products/{id}, /api/products/{id}/variants[/{variant_id}], /api/products/{id}/components atau /api/products/{id}/lots
 func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Request) {
+	if idStr, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/products/"), "/"); ok {
+		h.handleVariants(w, r, idStr, rest)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.GetByID(w, r)
@@ -78,7 +84,7 @@ func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	product, err := h.service.GetByID(id)
+	product, err := h.service.GetByID(id, storeFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -100,7 +106,7 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Update(id, &product)
+	err = h.service.Update(id, &product, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -116,7 +122,7 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid product ID", http.StatusBadRequest)
 		return
 	}
-	err = h.service.Delete(id)
+	err = h.service.Delete(id, actorFrom(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -124,3 +130,164 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Product deleted successfully"})
 }
+
+func (h *ProductHandler) handleVariants(w http.ResponseWriter, r *http.Request, idStr, rest string) {
+	productID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	if rest == "components" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetComponents(w, r, productID)
+		case http.MethodPut:
+			h.SetComponents(w, r, productID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if rest == "lots" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetLots(w, r, productID)
+		return
+	}
+
+	if rest == "variants" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetVariants(w, r, productID)
+		case http.MethodPost:
+			h.CreateVariant(w, r, productID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	variantStr, ok := strings.CutPrefix(rest, "variants/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	variantID, err := strconv.Atoi(variantStr)
+	if err != nil {
+		http.Error(w, "Invalid variant ID", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		h.UpdateVariant(w, r, productID, variantID)
+	case http.MethodDelete:
+		h.DeleteVariant(w, r, productID, variantID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ProductHandler) GetLots(w http.ResponseWriter, r *http.Request, productID int) {
+	lots, err := h.service.GetLots(productID, storeFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lots)
+}
+
+func (h *ProductHandler) GetVariants(w http.ResponseWriter, r *http.Request, productID int) {
+	product, err := h.service.GetByID(productID, storeFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	variants := product.Variants
+	if variants == nil {
+		variants = make([]models.ProductVariant, 0)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variants)
+}
+
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request, productID int) {
+	var variant models.ProductVariant
+	err := json.NewDecoder(r.Body).Decode(&variant)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.CreateVariant(productID, &variant, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request, productID, variantID int) {
+	var variant models.ProductVariant
+	err := json.NewDecoder(r.Body).Decode(&variant)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.UpdateVariant(productID, variantID, &variant, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request, productID, variantID int) {
+	err := h.service.DeleteVariant(productID, variantID, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Variant deleted successfully"})
+}
+
+func (h *ProductHandler) GetComponents(w http.ResponseWriter, r *http.Request, productID int) {
+	product, err := h.service.GetByID(productID, storeFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	components := product.Components
+	if components == nil {
+		components = make([]models.ProductComponent, 0)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"product_id": product.ID,
+		"cost_price": product.CostPrice,
+		"components": components,
+	})
+}
+
+// PUT /api/products/{id}/components with [{"component_id": 7, "quantity": 18}, ...]; an empty list makes the product plain again.
+func (h *ProductHandler) SetComponents(w http.ResponseWriter, r *http.Request, productID int) {
+	var components []models.ProductComponent
+	err := json.NewDecoder(r.Body).Decode(&components)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.SetComponents(productID, components, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetComponents(w, r, productID)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"kasir-api/services"
+	"net/http"
+)
+
+type ReceiptHandler struct {
+	service *services.ReceiptService
+}
+
+func NewReceiptHandler(service *services.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{
+		service: service,
+	}
+}
+
+// GET /api/transactions/{id}/receipt?format=text|escpos|pdf|html
+func (h *ReceiptHandler) GetReceipt(w http.ResponseWriter, r *http.Request, transactionID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, contentType, err := h.service.Render(transactionID, r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type ReportHandler struct {
+	service *services.ReportService
+}
+
+func NewReportHandler(service *services.ReportService) *ReportHandler {
+	return &ReportHandler{
+		service: service,
+	}
+}
+
+// parseStoreFilter reads ?store_id= of a report; 0 means all stores.
+func parseStoreFilter(q url.Values) (int, error) {
+	v := q.Get("store_id")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 0 {
+		return 0, errors.New("Invalid store_id")
+	}
+	return id, nil
+}
+
+// GET /api/reports/profit?from=2026-01-01&to=2026-01-31&group_by={product|category|day|month|store}&store_id=
+func (h *ReportHandler) HandleProfit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, err := parseBusinessDays(r.URL.Query(), h.service.BusinessDay())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	storeID, err := parseStoreFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.service.Profit(from, to, r.URL.Query().Get("group_by"), storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /api/reports/inventory-valuation?as_of=2026-01-31&store_id=
+func (h *ReportHandler) HandleInventoryValuation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	asOf, err := parseAsOf(r.URL.Query(), h.service.BusinessDay())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	storeID, err := parseStoreFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	valuation, err := h.service.InventoryValuation(asOf, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(valuation)
+}
+
+// GET /api/reports/stores?from=2026-01-01&to=2026-01-31
+func (h *ReportHandler) HandleStores(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, err := parseBusinessDays(r.URL.Query(), h.service.BusinessDay())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stores, err := h.service.StoreSales(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stores)
+}
+
+// GET /api/reports/sales?granularity={hour|day|week|month}&from=2026-01-01&to=2026-01-31&store_id=
+func (h *ReportHandler) HandleSales(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, err := parseBusinessDays(r.URL.Query(), h.service.BusinessDay())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	storeID, err := parseStoreFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.service.Sales(from, to, r.URL.Query().Get("granularity"), storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseRankingFilter reads ?from=&to=&sort_by={quantity|revenue|profit}&order={top|bottom}&limit=&store_id=&category_id=
+func (h *ReportHandler) parseRankingFilter(q url.Values) (models.RankingFilter, error) {
+	filter := models.RankingFilter{SortBy: q.Get("sort_by"), Order: q.Get("order")}
+	var err error
+	filter.From, filter.To, err = parseBusinessDays(q, h.service.BusinessDay())
+	if err != nil {
+		return filter, err
+	}
+	filter.StoreID, err = parseStoreFilter(q)
+	if err != nil {
+		return filter, err
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+	if v := q.Get("category_id"); v != "" {
+		if filter.CategoryID, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("Invalid category_id")
+		}
+	}
+	return filter, nil
+}
+
+// GET /api/reports/products?from=&to=&sort_by={quantity|revenue|profit}&order={top|bottom}&limit=10&store_id=&category_id=
+func (h *ReportHandler) HandleProducts(w http.ResponseWriter, r *http.Request) {
+	h.handleRanking(w, r, h.service.ProductRanking)
+}
+
+// GET /api/reports/categories?from=&to=&sort_by={quantity|revenue|profit}&order={top|bottom}&limit=10&store_id=
+func (h *ReportHandler) HandleCategories(w http.ResponseWriter, r *http.Request) {
+	h.handleRanking(w, r, h.service.CategoryRanking)
+}
+
+func (h *ReportHandler) handleRanking(w http.ResponseWriter, r *http.Request, rank func(models.RankingFilter) (*models.SalesRanking, error)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := h.parseRankingFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ranking, err := rank(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ranking)
+}
+
+// GET /api/reports/dead-stock?days=30&store_id=
+func (h *ReportHandler) HandleDeadStock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		days, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+	storeID, err := parseStoreFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lines, err := h.service.DeadStock(days, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lines)
+}
+
+// GET /api/reports/expiring?days=30
+func (h *ReportHandler) HandleExpiring(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		days, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+	lots, err := h.service.ExpiringLots(days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lots)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/middleware"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ShiftHandler struct {
+	service *services.ShiftService
+}
+
+func NewShiftHandler(service *services.ShiftService) *ShiftHandler {
+	return &ShiftHandler{
+		service: service,
+	}
+}
+
+func (h *ShiftHandler) HandleShifts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/shifts?status={open|closed}&date=2026-01-31
+func (h *ShiftHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	var date *time.Time
+	if v := r.URL.Query().Get("date"); v != "" {
+		day, err := time.ParseInLocation("2006-01-02", v, h.service.BusinessDay().Location)
+		if err != nil {
+			http.Error(w, "Invalid date, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		date = &day
+	}
+	shifts, err := h.service.GetAll(status, date)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shifts)
+}
+
+// /api/shifts/open, /api/shifts/current, /api/shifts/{id}, /api/shifts/{id}/{cash|close|report}
+func (h *ShiftHandler) HandleShiftByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/shifts/")
+
+	switch {
+	case path == "open" && r.Method == http.MethodPost:
+		h.Open(w, r)
+		return
+	case path == "current" && r.Method == http.MethodGet:
+		h.GetCurrent(w, r)
+		return
+	}
+
+	idStr, action, _ := strings.Cut(path, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid shift ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.canAccess(w, r, id) {
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.GetByID(w, r, id)
+	case action == "report" && r.Method == http.MethodGet:
+		h.Report(w, r, id)
+	case action == "cash" && r.Method == http.MethodPost:
+		h.AddCashMovement(w, r, id)
+	case action == "close" && r.Method == http.MethodPost:
+		h.Close(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// canAccess lets cashiers act only on their own shifts; owners and
+// managers may act on any. It writes the error response when it says no.
+func (h *ShiftHandler) canAccess(w http.ResponseWriter, r *http.Request, id int) bool {
+	claims, _ := middleware.ClaimsFromContext(r.Context())
+	if claims.Role == models.RoleOwner || claims.Role == models.RoleManager {
+		return true
+	}
+	shift, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return false
+	}
+	if shift.CashierID != claims.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (h *ShiftHandler) Open(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenShiftRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	claims, _ := middleware.ClaimsFromContext(r.Context())
+	shift, err := h.service.Open(claims.UserID, r.Header.Get("X-Register-ID"), req, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+func (h *ShiftHandler) GetCurrent(w http.ResponseWriter, r *http.Request) {
+	claims, _ := middleware.ClaimsFromContext(r.Context())
+	shift, err := h.service.GetOpenByCashier(claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+func (h *ShiftHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	shift, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+func (h *ShiftHandler) AddCashMovement(w http.ResponseWriter, r *http.Request, id int) {
+	var movement models.CashMovement
+	err := json.NewDecoder(r.Body).Decode(&movement)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	claims, _ := middleware.ClaimsFromContext(r.Context())
+	movement.ShiftID = id
+	movement.CreatedBy = claims.UserID
+	err = h.service.AddCashMovement(&movement, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(movement)
+}
+
+func (h *ShiftHandler) Close(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.CloseShiftRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	report, err := h.service.Close(id, req, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *ShiftHandler) Report(w http.ResponseWriter, r *http.Request, id int) {
+	report, err := h.service.Report(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
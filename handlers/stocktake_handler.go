@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type StocktakeHandler struct {
+	service *services.StocktakeService
+}
+
+func NewStocktakeHandler(service *services.StocktakeService) *StocktakeHandler {
+	return &StocktakeHandler{
+		service: service,
+	}
+}
+
+func (h *StocktakeHandler) HandleStocktakes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/stocktakes?status={open|approved|cancelled}
+func (h *StocktakeHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	stocktakes, err := h.service.GetAll(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktakes)
+}
+
+func (h *StocktakeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var stocktake models.Stocktake
+	err := json.NewDecoder(r.Body).Decode(&stocktake)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.Create(&stocktake, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+// /api/stocktakes/{id}, /api/stocktakes/{id}/counts, /api/stocktakes/{id}/approve atau /api/stocktakes/{id}/cancel
+func (h *StocktakeHandler) HandleStocktakeByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/stocktakes/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.GetByID(w, r, id)
+	case action == "counts" && r.Method == http.MethodPut:
+		h.Count(w, r, id)
+	case action == "approve" && r.Method == http.MethodPost:
+		h.Approve(w, r, id)
+	case action == "cancel" && r.Method == http.MethodPost:
+		h.Cancel(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	stocktake, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+func (h *StocktakeHandler) Count(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.StocktakeCountRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	stocktake, err := h.service.Count(id, req, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+func (h *StocktakeHandler) Approve(w http.ResponseWriter, r *http.Request, id int) {
+	stocktake, err := h.service.Approve(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+func (h *StocktakeHandler) Cancel(w http.ResponseWriter, r *http.Request, id int) {
+	stocktake, err := h.service.Cancel(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
@@ -5,15 +5,19 @@ import (
 	"kasir-api/models"
 	"kasir-api/services"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type TransactionHandler struct {
-	service *services.TransactionService
+	service  *services.TransactionService
+	receipts *ReceiptHandler
 }
 
-func NewTransactionHandler(service *services.TransactionService) *TransactionHandler {
+func NewTransactionHandler(service *services.TransactionService, receipts *ReceiptHandler) *TransactionHandler {
 	return &TransactionHandler{
-		service: service,
+		service:  service,
+		receipts: receipts,
 	}
 }
 
@@ -35,7 +39,8 @@ func (h *TransactionHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transaction, err := h.service.Checkout(req.Items)
+	setCheckoutContext(r, &req)
+	transaction, err := h.service.Checkout(req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -45,6 +50,160 @@ func (h *TransactionHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(transaction)
 }
 
+// setCheckoutContext records who rang up the sale and on which register.
+func setCheckoutContext(r *http.Request, req *models.CheckoutRequest) {
+	req.Actor = actorFrom(r)
+	req.CashierID = req.Actor.UserID
+	req.RegisterID = r.Header.Get("X-Register-ID")
+}
+
+// hitung total tanpa simpan transaksi / potong stok
+func (h *TransactionHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Preview(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TransactionHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req models.CheckoutRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setCheckoutContext(r, &req)
+	transaction, err := h.service.Preview(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/transactions?store_id=&cashier_id=&customer_id=&register_id=&from=2026-01-01&to=2026-01-31&limit=
+func (h *TransactionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter models.TransactionFilter
+	var err error
+
+	filter.StoreID, err = parseStoreFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("cashier_id"); v != "" {
+		filter.CashierID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid cashier_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("customer_id"); v != "" {
+		filter.CustomerID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid customer_id", http.StatusBadRequest)
+			return
+		}
+	}
+	filter.RegisterID = q.Get("register_id")
+	if q.Get("from") != "" || q.Get("to") != "" {
+		filter.From, filter.To, err = parseBusinessDays(q, h.service.BusinessDay())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	transactions, err := h.service.GetAll(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// /api/transactions/{id}, /api/transactions/{id}/receipt, /api/transactions/{id}/refund atau /api/transactions/by-number/{number}
+func (h *TransactionHandler) HandleTransactionByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/transactions/")
+	if number, ok := strings.CutPrefix(path, "by-number/"); ok && r.Method == http.MethodGet {
+		h.GetByReceiptNumber(w, r, number)
+		return
+	}
+
+	idStr, action, _ := strings.Cut(path, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "refund" && r.Method == http.MethodPost:
+		h.Refund(w, r, id)
+	case r.Method != http.MethodGet:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case action == "":
+		h.GetByID(w, r, id)
+	case action == "receipt":
+		h.receipts.GetReceipt(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *TransactionHandler) Refund(w http.ResponseWriter, r *http.Request, id int) {
+	transaction, err := h.service.Refund(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func (h *TransactionHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	transaction, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func (h *TransactionHandler) GetByReceiptNumber(w http.ResponseWriter, r *http.Request, number string) {
+	transaction, err := h.service.GetByReceiptNumber(number)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
 func (h *TransactionHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -54,8 +213,14 @@ func (h *TransactionHandler) HandleReport(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// GET /api/report/today?store_id=
 func (h *TransactionHandler) GetReport(w http.ResponseWriter, r *http.Request) {
-	report, err := h.service.GetReportToday()
+	storeID, err := parseStoreFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.service.GetReportToday(storeID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
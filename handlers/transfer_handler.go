@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type TransferHandler struct {
+	service *services.TransferService
+}
+
+func NewTransferHandler(service *services.TransferService) *TransferHandler {
+	return &TransferHandler{
+		service: service,
+	}
+}
+
+func (h *TransferHandler) HandleTransfers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/transfers?store_id=&status={requested|in_transit|received|cancelled}
+func (h *TransferHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, r.URL.Query().Get("status"))
+}
+
+// GET /api/transfers/in-transit?store_id=
+func (h *TransferHandler) InTransit(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, models.TransferInTransit)
+}
+
+func (h *TransferHandler) list(w http.ResponseWriter, r *http.Request, status string) {
+	filter := models.StockTransferFilter{Status: status}
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		var err error
+		filter.StoreID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid store_id", http.StatusBadRequest)
+			return
+		}
+	}
+	transfers, err := h.service.GetAll(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (h *TransferHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var t models.StockTransfer
+	err := json.NewDecoder(r.Body).Decode(&t)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	err = h.service.Create(&t, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// /api/transfers/in-transit, /api/transfers/{id} atau /api/transfers/{id}/{dispatch|receive|cancel}
+func (h *TransferHandler) HandleTransferByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/transfers/")
+	if path == "in-transit" && r.Method == http.MethodGet {
+		h.InTransit(w, r)
+		return
+	}
+
+	idStr, action, _ := strings.Cut(path, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.GetByID(w, r, id)
+	case action == "dispatch" && r.Method == http.MethodPost:
+		h.Dispatch(w, r, id)
+	case action == "receive" && r.Method == http.MethodPost:
+		h.Receive(w, r, id)
+	case action == "cancel" && r.Method == http.MethodPost:
+		h.Cancel(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TransferHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	t, err := h.service.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *TransferHandler) Dispatch(w http.ResponseWriter, r *http.Request, id int) {
+	t, err := h.service.Dispatch(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// POST /api/transfers/{id}/receive, an empty body receives everything as dispatched
+func (h *TransferHandler) Receive(w http.ResponseWriter, r *http.Request, id int) {
+	var receipt models.TransferReceipt
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	t, err := h.service.Receive(id, &receipt, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *TransferHandler) Cancel(w http.ResponseWriter, r *http.Request, id int) {
+	t, err := h.service.Cancel(id, actorFrom(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"kasir-api/config"
 	"kasir-api/handlers"
+	"kasir-api/middleware"
+	"kasir-api/models"
 	"kasir-api/repositories"
 	"kasir-api/services"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -18,6 +24,25 @@ import (
 type Config struct {
 	Port string `mapstructure:"PORT"`
 	DBConn string `mapstructure:"DB_CONN"`
+	ReservationSweepInterval time.Duration `mapstructure:"RESERVATION_SWEEP_INTERVAL"`
+	StoreCode string `mapstructure:"STORE_CODE"`
+	ReceiptNumberFormat string `mapstructure:"RECEIPT_NUMBER_FORMAT"`
+	ReceiptSequenceReset string `mapstructure:"RECEIPT_SEQUENCE_RESET"`
+	StoreName string `mapstructure:"STORE_NAME"`
+	StoreAddress string `mapstructure:"STORE_ADDRESS"`
+	StorePhone string `mapstructure:"STORE_PHONE"`
+	ReceiptFooter string `mapstructure:"RECEIPT_FOOTER"`
+	ReceiptWidth int `mapstructure:"RECEIPT_WIDTH"`
+	ReceiptTextTemplate string `mapstructure:"RECEIPT_TEXT_TEMPLATE"`
+	ReceiptHTMLTemplate string `mapstructure:"RECEIPT_HTML_TEMPLATE"`
+	JWTSecret string `mapstructure:"JWT_SECRET"`
+	AccessTokenTTL time.Duration `mapstructure:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
+	AdminUsername string `mapstructure:"ADMIN_USERNAME"`
+	AdminPassword string `mapstructure:"ADMIN_PASSWORD"`
+	InventoryCosting string `mapstructure:"INVENTORY_COSTING"`
+	StoreTimezone string `mapstructure:"STORE_TIMEZONE"`
+	BusinessDayCutoff string `mapstructure:"BUSINESS_DAY_CUTOFF"`
 }
 
 func main() {
@@ -32,6 +57,28 @@ func main() {
 	env := Config{
 		Port: viper.GetString("PORT"),
 		DBConn: viper.GetString("DB_CONN"),
+		ReservationSweepInterval: viper.GetDuration("RESERVATION_SWEEP_INTERVAL"),
+		StoreCode: viper.GetString("STORE_CODE"),
+		ReceiptNumberFormat: viper.GetString("RECEIPT_NUMBER_FORMAT"),
+		ReceiptSequenceReset: viper.GetString("RECEIPT_SEQUENCE_RESET"),
+		StoreName: viper.GetString("STORE_NAME"),
+		StoreAddress: viper.GetString("STORE_ADDRESS"),
+		StorePhone: viper.GetString("STORE_PHONE"),
+		ReceiptFooter: viper.GetString("RECEIPT_FOOTER"),
+		ReceiptWidth: viper.GetInt("RECEIPT_WIDTH"),
+		ReceiptTextTemplate: viper.GetString("RECEIPT_TEXT_TEMPLATE"),
+		ReceiptHTMLTemplate: viper.GetString("RECEIPT_HTML_TEMPLATE"),
+		JWTSecret: viper.GetString("JWT_SECRET"),
+		AccessTokenTTL: viper.GetDuration("ACCESS_TOKEN_TTL"),
+		RefreshTokenTTL: viper.GetDuration("REFRESH_TOKEN_TTL"),
+		AdminUsername: viper.GetString("ADMIN_USERNAME"),
+		AdminPassword: viper.GetString("ADMIN_PASSWORD"),
+		InventoryCosting: viper.GetString("INVENTORY_COSTING"),
+		StoreTimezone: viper.GetString("STORE_TIMEZONE"),
+		BusinessDayCutoff: viper.GetString("BUSINESS_DAY_CUTOFF"),
+	}
+	if env.ReservationSweepInterval <= 0 {
+		env.ReservationSweepInterval = time.Minute
 	}
 	
 	//setup database
@@ -41,25 +88,177 @@ func main() {
 	}
 	defer db.Close()
 
-	productRepo := repositories.NewProductRepository(db)
+	if err := config.EnsureSchema(db); err != nil {
+		log.Fatal("Failed to apply database schema: ", err)
+	}
+
+	userRepo := repositories.NewUserRepository(db)
+	authService, err := services.NewAuthService(userRepo, env.JWTSecret, env.AccessTokenTTL, env.RefreshTokenTTL)
+	if err != nil {
+		log.Fatal("Invalid auth config: ", err)
+	}
+	if err := authService.EnsureOwner(env.AdminUsername, env.AdminPassword); err != nil {
+		log.Fatal("Failed to create first owner: ", err)
+	}
+	authHandler := handlers.NewAuthHandler(authService)
+	userService := services.NewUserService(userRepo)
+	userHandler := handlers.NewUserHandler(userService)
+	auth := middleware.NewAuth(authService)
+
+	businessDay, err := models.NewBusinessDay(env.StoreTimezone, env.BusinessDayCutoff)
+	if err != nil {
+		log.Fatal("Invalid business day config: ", err)
+	}
+
+	auditRepo := repositories.NewAuditRepository(db)
+	auditService := services.NewAuditService(auditRepo, businessDay)
+	auditHandler := handlers.NewAuditHandler(auditService)
+
+	stockLedger, err := repositories.NewStockLedger(env.InventoryCosting)
+	if err != nil {
+		log.Fatal("Invalid inventory config: ", err)
+	}
+
+	productRepo := repositories.NewProductRepository(db, stockLedger, businessDay)
 	productService := services.NewProductService(productRepo)
 	productHandler := handlers.NewProductHandler(productService)
 
+	modifierRepo := repositories.NewModifierRepository(db)
+	modifierService := services.NewModifierService(modifierRepo, businessDay)
+	modifierHandler := handlers.NewModifierHandler(modifierService)
+
 	categoryRepo := repositories.NewCategoryRepository(db)
 	categoryService := services.NewCategoryService(categoryRepo)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
-	transactionRepo := repositories.NewTransactionRepository(db)
+	receiptNumbers, err := repositories.NewReceiptNumberGenerator(env.ReceiptNumberFormat, env.StoreCode, env.ReceiptSequenceReset, businessDay)
+	if err != nil {
+		log.Fatal("Invalid receipt number config: ", err)
+	}
+
+	storeRepo := repositories.NewStoreRepository(db)
+	storeService := services.NewStoreService(storeRepo)
+	storeHandler := handlers.NewStoreHandler(storeService)
+
+	transactionRepo := repositories.NewTransactionRepository(db, receiptNumbers, stockLedger, businessDay)
 	transactionService := services.NewTransactionService(transactionRepo)
-	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	receiptService, err := services.NewReceiptService(transactionRepo, storeRepo, services.ReceiptSettings{
+		StoreName:        env.StoreName,
+		StoreAddress:     env.StoreAddress,
+		StorePhone:       env.StorePhone,
+		Footer:           env.ReceiptFooter,
+		Width:            env.ReceiptWidth,
+		TextTemplatePath: env.ReceiptTextTemplate,
+		HTMLTemplatePath: env.ReceiptHTMLTemplate,
+	})
+	if err != nil {
+		log.Fatal("Invalid receipt template: ", err)
+	}
+	receiptHandler := handlers.NewReceiptHandler(receiptService)
+	transactionHandler := handlers.NewTransactionHandler(transactionService, receiptHandler)
+
+	goodsReceiptRepo := repositories.NewGoodsReceiptRepository(db, stockLedger)
+	goodsReceiptService := services.NewGoodsReceiptService(goodsReceiptRepo, businessDay)
+	goodsReceiptHandler := handlers.NewGoodsReceiptHandler(goodsReceiptService)
+
+	supplierRepo := repositories.NewSupplierRepository(db)
+	supplierService := services.NewSupplierService(supplierRepo)
+	supplierHandler := handlers.NewSupplierHandler(supplierService)
+
+	purchaseOrderRepo := repositories.NewPurchaseOrderRepository(db, stockLedger)
+	purchaseOrderService := services.NewPurchaseOrderService(purchaseOrderRepo)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService)
+
+	transferRepo := repositories.NewTransferRepository(db, stockLedger, businessDay)
+	transferService := services.NewTransferService(transferRepo)
+	transferHandler := handlers.NewTransferHandler(transferService)
+
+	stocktakeRepo := repositories.NewStocktakeRepository(db, stockLedger)
+	stocktakeService := services.NewStocktakeService(stocktakeRepo)
+	stocktakeHandler := handlers.NewStocktakeHandler(stocktakeService)
+
+	reportRepo := repositories.NewReportRepository(db, stockLedger, businessDay)
+	reportService := services.NewReportService(reportRepo)
+	reportHandler := handlers.NewReportHandler(reportService)
+
+	reservationRepo := repositories.NewReservationRepository(db)
+	reservationService := services.NewReservationService(reservationRepo)
+	reservationHandler := handlers.NewReservationHandler(reservationService)
+
+	loyaltyRepo := repositories.NewLoyaltyRepository(db)
+	loyaltyService := services.NewLoyaltyService(loyaltyRepo)
+	loyaltyHandler := handlers.NewLoyaltyHandler(loyaltyService)
+
+	giftCardRepo := repositories.NewGiftCardRepository(db)
+	giftCardService := services.NewGiftCardService(giftCardRepo)
+	giftCardHandler := handlers.NewGiftCardHandler(giftCardService)
+
+	customerRepo := repositories.NewCustomerRepository(db)
+	customerService := services.NewCustomerService(customerRepo, transactionRepo)
+	customerHandler := handlers.NewCustomerHandler(customerService, loyaltyHandler)
+
+	shiftRepo := repositories.NewShiftRepository(db, businessDay)
+	shiftService := services.NewShiftService(shiftRepo)
+	shiftHandler := handlers.NewShiftHandler(shiftService)
+
+	cartRepo := repositories.NewCartRepository(db)
+	cartService := services.NewCartService(cartRepo, transactionRepo)
+	cartHandler := handlers.NewCartHandler(cartService)
 
 	//setup router
-	http.HandleFunc("/api/products", productHandler.HandleProducts)
-	http.HandleFunc("/api/products/", productHandler.HandleProductByID)
-	http.HandleFunc("/api/categories", categoryHandler.HandleCategories)
-	http.HandleFunc("/api/categories/", categoryHandler.HandleCategoryByID)
-	http.HandleFunc("/api/checkout", transactionHandler.HandleCheckout)
-	http.HandleFunc("/api/report/today", transactionHandler.HandleReport)
+	owners := []string{models.RoleOwner}
+	managers := []string{models.RoleOwner, models.RoleManager}
+
+	http.HandleFunc("/api/auth/login", authHandler.HandleLogin)
+	http.HandleFunc("/api/auth/refresh", authHandler.HandleRefresh)
+	http.HandleFunc("/api/auth/logout", authHandler.HandleLogout)
+	http.HandleFunc("/api/auth/me", auth.Protect(authHandler.HandleMe, nil))
+	http.HandleFunc("/api/users", auth.Protect(userHandler.HandleUsers, middleware.Roles{middleware.AnyMethod: owners}))
+	http.HandleFunc("/api/users/", auth.Protect(userHandler.HandleUserByID, middleware.Roles{middleware.AnyMethod: owners}))
+	http.HandleFunc("/api/stores", auth.Protect(storeHandler.HandleStores, middleware.Roles{http.MethodPost: owners}))
+	http.HandleFunc("/api/stores/", auth.Protect(storeHandler.HandleStoreByID, middleware.Roles{http.MethodPut: managers, http.MethodDelete: owners}))
+	http.HandleFunc("/api/products", auth.Protect(productHandler.HandleProducts, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/products/", auth.Protect(productHandler.HandleProductByID, middleware.Roles{http.MethodPost: managers, http.MethodPut: managers, http.MethodDelete: managers}))
+	http.HandleFunc("/api/modifier-groups", auth.Protect(modifierHandler.HandleModifierGroups, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/modifier-groups/", auth.Protect(modifierHandler.HandleModifierGroupByID, middleware.Roles{http.MethodPut: managers, http.MethodDelete: managers}))
+	http.HandleFunc("/api/goods-receipts", auth.Protect(goodsReceiptHandler.HandleGoodsReceipts, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/goods-receipts/", auth.Protect(goodsReceiptHandler.HandleGoodsReceiptByID, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/suppliers", auth.Protect(supplierHandler.HandleSuppliers, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/suppliers/", auth.Protect(supplierHandler.HandleSupplierByID, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/purchase-orders", auth.Protect(purchaseOrderHandler.HandlePurchaseOrders, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/purchase-orders/", auth.Protect(purchaseOrderHandler.HandlePurchaseOrderByID, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/transfers", auth.Protect(transferHandler.HandleTransfers, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/transfers/", auth.Protect(transferHandler.HandleTransferByID, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/stocktakes", auth.Protect(stocktakeHandler.HandleStocktakes, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/stocktakes/", auth.Protect(stocktakeHandler.HandleStocktakeByID, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/categories", auth.Protect(categoryHandler.HandleCategories, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/categories/", auth.Protect(categoryHandler.HandleCategoryByID, middleware.Roles{http.MethodPut: managers, http.MethodDelete: managers}))
+	http.HandleFunc("/api/checkout", auth.Protect(transactionHandler.HandleCheckout, nil))
+	http.HandleFunc("/api/checkout/preview", auth.Protect(transactionHandler.HandlePreview, nil))
+	http.HandleFunc("/api/transactions", auth.Protect(transactionHandler.HandleTransactions, nil))
+	http.HandleFunc("/api/transactions/", auth.Protect(transactionHandler.HandleTransactionByID, middleware.Roles{http.MethodPost: managers}))
+	http.HandleFunc("/api/carts", auth.Protect(cartHandler.HandleCarts, nil))
+	http.HandleFunc("/api/carts/", auth.Protect(cartHandler.HandleCartByID, nil))
+	http.HandleFunc("/api/reservations", auth.Protect(reservationHandler.HandleReservations, nil))
+	http.HandleFunc("/api/reservations/", auth.Protect(reservationHandler.HandleReservationByID, nil))
+	http.HandleFunc("/api/customers", auth.Protect(customerHandler.HandleCustomers, nil))
+	http.HandleFunc("/api/customers/", auth.Protect(customerHandler.HandleCustomerByID, middleware.Roles{http.MethodDelete: managers}))
+	http.HandleFunc("/api/gift-cards", auth.Protect(giftCardHandler.HandleGiftCards, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/gift-cards/", auth.Protect(giftCardHandler.HandleGiftCardByCode, middleware.Roles{http.MethodDelete: managers}))
+	http.HandleFunc("/api/loyalty/rules", auth.Protect(loyaltyHandler.HandleRules, middleware.Roles{http.MethodPut: managers}))
+	http.HandleFunc("/api/shifts", auth.Protect(shiftHandler.HandleShifts, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/shifts/", auth.Protect(shiftHandler.HandleShiftByID, nil))
+	http.HandleFunc("/api/audit", auth.Protect(auditHandler.HandleAudit, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/report/today", auth.Protect(transactionHandler.HandleReport, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/profit", auth.Protect(reportHandler.HandleProfit, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/inventory-valuation", auth.Protect(reportHandler.HandleInventoryValuation, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/sales", auth.Protect(reportHandler.HandleSales, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/products", auth.Protect(reportHandler.HandleProducts, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/categories", auth.Protect(reportHandler.HandleCategories, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/dead-stock", auth.Protect(reportHandler.HandleDeadStock, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/stores", auth.Protect(reportHandler.HandleStores, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/expiring", auth.Protect(reportHandler.HandleExpiring, middleware.Roles{middleware.AnyMethod: managers}))
+	http.HandleFunc("/api/reports/modifiers", auth.Protect(modifierHandler.HandlePopularity, middleware.Roles{middleware.AnyMethod: managers}))
 
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -78,11 +277,92 @@ func main() {
 		
 		//Build endpoint metadata for current kasir API
 		endpoints := map[string]map[string]map[string]string{
+			"Auth": {
+				"login": {
+					"method": "POST",
+					"path":   "/api/auth/login",
+					"description": "Sign in and get access and refresh tokens",
+				},
+				"refresh": {
+					"method": "POST",
+					"path":   "/api/auth/refresh",
+					"description": "Trade a refresh token for new tokens",
+				},
+				"logout": {
+					"method": "POST",
+					"path":   "/api/auth/logout",
+					"description": "Revoke a refresh token",
+				},
+				"me": {
+					"method": "GET",
+					"path":   "/api/auth/me",
+					"description": "Show the signed-in user",
+				},
+			},
+			"Users": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/users",
+					"description": "List users (owner only)",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/users",
+					"description": "Create a user (owner only)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/users/{id}",
+					"description": "Get a user by ID (owner only)",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/users/{id}",
+					"description": "Update role, store, status or password (owner only)",
+				},
+			},
+			"Stores": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/stores",
+					"description": "List stores; owners and managers send X-Store-ID on any request to act for one, the default store otherwise. Cashiers act for their own store_id",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/stores",
+					"description": "Create a store; the first one is the default store (owner only)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/stores/{id}",
+					"description": "Get a store",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/stores/{id}",
+					"description": "Update a store's code, name, address and phone (managers only)",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/stores/{id}",
+					"description": "Delete a store without stock or sales (owner only)",
+				},
+				"products": {
+					"method": "GET",
+					"path":   "/api/stores/{id}/products",
+					"description": "Stock and selling price of every product at the store",
+				},
+				"prices": {
+					"method": "PUT",
+					"path":   "/api/stores/{id}/prices",
+					"description": "Set or clear (price null) the store's price overrides (managers only)",
+				},
+			},
 			"Products": {
 				"list": {
 					"method": "GET",
 					"path":   "/api/products",
-					"description": "List all products",
+					"description": "List all products with the price and stock of the X-Store-ID store",
 				},
 				"create": {
 					"method": "POST",
@@ -104,6 +384,243 @@ func main() {
 					"path":   "/api/products/{id}",
 					"description": "Delete a product by ID",
 				},
+				"variants": {
+					"method": "GET",
+					"path":   "/api/products/{id}/variants",
+					"description": "List a product's variants; sell them at checkout with variant_id",
+				},
+				"create_variant": {
+					"method": "POST",
+					"path":   "/api/products/{id}/variants",
+					"description": "Add a variant with its own SKU, price and stock",
+				},
+				"update_variant": {
+					"method": "PUT",
+					"path":   "/api/products/{id}/variants/{variant_id}",
+					"description": "Update a variant",
+				},
+				"delete_variant": {
+					"method": "DELETE",
+					"path":   "/api/products/{id}/variants/{variant_id}",
+					"description": "Delete a variant",
+				},
+				"lots": {
+					"method": "GET",
+					"path":   "/api/products/{id}/lots",
+					"description": "Lots of a lot-tracked product with stock left at the store, soonest expiry first",
+				},
+				"components": {
+					"method": "GET",
+					"path":   "/api/products/{id}/components",
+					"description": "Recipe of a composite product with its cost from component costs",
+				},
+				"set_components": {
+					"method": "PUT",
+					"path":   "/api/products/{id}/components",
+					"description": "Replace the recipe; checkout then takes stock from the components",
+				},
+			},
+			"Modifier groups": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/modifier-groups",
+					"description": "List modifier groups with their options and linked products",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/modifier-groups",
+					"description": "Create a modifier group (required, min/max selections, price deltas)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/modifier-groups/{id}",
+					"description": "Get a modifier group by ID",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/modifier-groups/{id}",
+					"description": "Update a modifier group, its options and linked products",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/modifier-groups/{id}",
+					"description": "Delete a modifier group",
+				},
+			},
+			"Suppliers": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/suppliers?name=",
+					"description": "List suppliers (managers only)",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/suppliers",
+					"description": "Create a supplier",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/suppliers/{id}",
+					"description": "Get a supplier",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/suppliers/{id}",
+					"description": "Update a supplier",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/suppliers/{id}",
+					"description": "Delete a supplier without purchase orders",
+				},
+				"products": {
+					"method": "GET",
+					"path":   "/api/suppliers/{id}/products",
+					"description": "Products the supplier sells, with supplier SKU, last cost and reorder levels",
+				},
+				"set products": {
+					"method": "PUT",
+					"path":   "/api/suppliers/{id}/products",
+					"description": "Add or update product links: product_id, variant_id, supplier_sku, last_cost, min_stock, max_stock",
+				},
+				"delete product": {
+					"method": "DELETE",
+					"path":   "/api/suppliers/{id}/products/{product_id}?variant_id=",
+					"description": "Remove a product link",
+				},
+			},
+			"Purchase orders": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/purchase-orders?supplier_id=&status={draft|sent|partially_received|closed}",
+					"description": "List purchase orders (managers only)",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/purchase-orders",
+					"description": "Create a draft purchase order",
+				},
+				"suggestions": {
+					"method": "GET",
+					"path":   "/api/purchase-orders/suggestions?supplier_id=",
+					"description": "Draft orders for linked products at or below their minimum stock at the X-Store-ID store",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/purchase-orders/{id}",
+					"description": "Get a purchase order with ordered and received quantities",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/purchase-orders/{id}",
+					"description": "Edit a draft",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/purchase-orders/{id}",
+					"description": "Delete a draft",
+				},
+				"send": {
+					"method": "POST",
+					"path":   "/api/purchase-orders/{id}/send",
+					"description": "Mark a draft as sent to the supplier",
+				},
+				"receive": {
+					"method": "POST",
+					"path":   "/api/purchase-orders/{id}/receive",
+					"description": "Post a goods receipt against the order, adding stock at cost",
+				},
+				"close": {
+					"method": "POST",
+					"path":   "/api/purchase-orders/{id}/close",
+					"description": "Close an order that will not be delivered in full",
+				},
+			},
+			"Transfers": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/transfers?store_id=&status={requested|in_transit|received|cancelled}",
+					"description": "List stock transfers from or to a store (managers only)",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/transfers",
+					"description": "Request a transfer of stock from one store to another",
+				},
+				"in-transit": {
+					"method": "GET",
+					"path":   "/api/transfers/in-transit?store_id=",
+					"description": "Transfers dispatched but not yet received, with their lines",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/transfers/{id}",
+					"description": "Get a transfer with requested, dispatched and received quantities and discrepancies",
+				},
+				"dispatch": {
+					"method": "POST",
+					"path":   "/api/transfers/{id}/dispatch",
+					"description": "Take the requested stock out of the source store",
+				},
+				"receive": {
+					"method": "POST",
+					"path":   "/api/transfers/{id}/receive",
+					"description": "Book what arrived into the destination store; lines left out arrived in full",
+				},
+				"cancel": {
+					"method": "POST",
+					"path":   "/api/transfers/{id}/cancel",
+					"description": "Cancel a transfer that has not been dispatched",
+				},
+			},
+			"Goods receipts": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/goods-receipts?from=&to=",
+					"description": "List goods receipts (managers only)",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/goods-receipts",
+					"description": "Receive stock with its cost price (managers only)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/goods-receipts/{id}",
+					"description": "Get a goods receipt with its items",
+				},
+			},
+			"Stocktakes": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/stocktakes?status={open|approved|cancelled}",
+					"description": "List stocktake sessions",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/stocktakes",
+					"description": "Start a stocktake, snapshotting expected stock, optionally for one category_id (managers only)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/stocktakes/{id}",
+					"description": "Get a stocktake with expected, counted and variance per line",
+				},
+				"count": {
+					"method": "PUT",
+					"path":   "/api/stocktakes/{id}/counts",
+					"description": "Record a batch of counts by product_id/variant_id or sku; mode set or add (for scanners)",
+				},
+				"approve": {
+					"method": "POST",
+					"path":   "/api/stocktakes/{id}/approve",
+					"description": "Post counted variances as stock adjustments and close the stocktake (managers only)",
+				},
+				"cancel": {
+					"method": "POST",
+					"path":   "/api/stocktakes/{id}/cancel",
+					"description": "Close the stocktake without posting anything (managers only)",
+				},
 			},
 			"Categories": {
 				"list": {
@@ -132,6 +649,281 @@ func main() {
 					"description": "Delete a category by ID",
 				},
 			},
+			"Checkout": {
+				"checkout": {
+					"method": "POST",
+					"path":   "/api/checkout",
+					"description": "Create a transaction and take stock",
+				},
+				"preview": {
+					"method": "POST",
+					"path":   "/api/checkout/preview",
+					"description": "Quote a checkout without saving it or taking stock",
+				},
+			},
+			"Transactions": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/transactions?store_id=&cashier_id=&register_id=&from=&to=&limit=",
+					"description": "Transaction history, filterable by cashier, register and date",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/transactions/{id}",
+					"description": "Get a transaction by ID",
+				},
+				"receipt": {
+					"method": "GET",
+					"path":   "/api/transactions/{id}/receipt?format={text|escpos|pdf|html}",
+					"description": "Render a printable receipt",
+				},
+				"get_by_number": {
+					"method": "GET",
+					"path":   "/api/transactions/by-number/{number}",
+					"description": "Get a transaction by its receipt number",
+				},
+				"refund": {
+					"method": "POST",
+					"path":   "/api/transactions/{id}/refund",
+					"description": "Refund a transaction: restock, reverse loyalty points (managers only)",
+				},
+			},
+			"Carts": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/carts?status={open|held|checked_out}",
+					"description": "List carts, optionally by status",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/carts",
+					"description": "Open a new cart",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/carts/{id}",
+					"description": "Get a cart by ID",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/carts/{id}",
+					"description": "Discard a cart",
+				},
+				"add_item": {
+					"method": "POST",
+					"path":   "/api/carts/{id}/items",
+					"description": "Add a product to a cart",
+				},
+				"update_item": {
+					"method": "PUT",
+					"path":   "/api/carts/{id}/items/{product_id}",
+					"description": "Change the quantity of a cart line",
+				},
+				"remove_item": {
+					"method": "DELETE",
+					"path":   "/api/carts/{id}/items/{product_id}",
+					"description": "Remove a line from a cart",
+				},
+				"hold": {
+					"method": "POST",
+					"path":   "/api/carts/{id}/hold",
+					"description": "Park a cart, optionally reserving its stock",
+				},
+				"resume": {
+					"method": "POST",
+					"path":   "/api/carts/{id}/resume",
+					"description": "Reopen a held cart",
+				},
+				"checkout": {
+					"method": "POST",
+					"path":   "/api/carts/{id}/checkout",
+					"description": "Check out a cart as a transaction",
+				},
+			},
+			"Reservations": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/reservations?reference={reference}",
+					"description": "List active stock reservations",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/reservations",
+					"description": "Reserve stock under a reference for a limited time",
+				},
+				"release": {
+					"method": "DELETE",
+					"path":   "/api/reservations/{id}",
+					"description": "Release a stock reservation",
+				},
+			},
+			"Customers": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/customers?name=&phone=",
+					"description": "List customers, searchable by name or phone",
+				},
+				"create": {
+					"method": "POST",
+					"path":   "/api/customers",
+					"description": "Create a customer",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/customers/{id}",
+					"description": "Get a customer by ID",
+				},
+				"update": {
+					"method": "PUT",
+					"path":   "/api/customers/{id}",
+					"description": "Update a customer",
+				},
+				"delete": {
+					"method": "DELETE",
+					"path":   "/api/customers/{id}",
+					"description": "Delete a customer (managers only)",
+				},
+				"transactions": {
+					"method": "GET",
+					"path":   "/api/customers/{id}/transactions",
+					"description": "Customer purchase history with lifetime spend and visits",
+				},
+				"points": {
+					"method": "GET",
+					"path":   "/api/customers/{id}/points",
+					"description": "Loyalty points balance and ledger",
+				},
+			},
+			"Gift cards": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/gift-cards?customer_id=",
+					"description": "List gift cards and store credit (managers only)",
+				},
+				"issue": {
+					"method": "POST",
+					"path":   "/api/gift-cards",
+					"description": "Issue a gift card; the code is generated when left empty, paid_cash books the sale into the issuer's open shift (managers only)",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/gift-cards/{code}",
+					"description": "Balance lookup with the card's ledger; spend it at checkout with method gift_card and the code as reference",
+				},
+				"void": {
+					"method": "DELETE",
+					"path":   "/api/gift-cards/{code}",
+					"description": "Void a gift card (managers only)",
+				},
+			},
+			"Loyalty": {
+				"rules": {
+					"method": "GET",
+					"path":   "/api/loyalty/rules",
+					"description": "Get the points earn and redeem rules",
+				},
+				"update_rules": {
+					"method": "PUT",
+					"path":   "/api/loyalty/rules",
+					"description": "Update the rules and category multipliers (managers only)",
+				},
+			},
+			"Shifts": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/shifts?status={open|closed}&date=",
+					"description": "List shifts, ?date= for those opened on a business day (managers only)",
+				},
+				"open": {
+					"method": "POST",
+					"path":   "/api/shifts/open",
+					"description": "Open a shift with the opening cash float",
+				},
+				"current": {
+					"method": "GET",
+					"path":   "/api/shifts/current",
+					"description": "Get the signed-in cashier's open shift",
+				},
+				"get": {
+					"method": "GET",
+					"path":   "/api/shifts/{id}",
+					"description": "Get a shift by ID",
+				},
+				"cash": {
+					"method": "POST",
+					"path":   "/api/shifts/{id}/cash",
+					"description": "Record cash put into or taken out of the drawer",
+				},
+				"close": {
+					"method": "POST",
+					"path":   "/api/shifts/{id}/close",
+					"description": "Close a shift with the counted cash and get its Z-report",
+				},
+				"report": {
+					"method": "GET",
+					"path":   "/api/shifts/{id}/report",
+					"description": "Z-report summary of a shift",
+				},
+			},
+			"Audit": {
+				"list": {
+					"method": "GET",
+					"path":   "/api/audit?entity_type=&entity_id=&actor_id=&from=&to=",
+					"description": "Audit log of changes (managers only)",
+				},
+			},
+			"Reports": {
+				"today": {
+					"method": "GET",
+					"path":   "/api/report/today",
+					"description": "The current business day's revenue, best seller and sales per cashier, ?store_id= for one store (managers only)",
+				},
+				"profit": {
+					"method": "GET",
+					"path":   "/api/reports/profit?from=&to=&group_by={product|category|day|month|store}&store_id=",
+					"description": "Revenue, COGS, gross profit and margin (managers only)",
+				},
+				"inventory-valuation": {
+					"method": "GET",
+					"path":   "/api/reports/inventory-valuation?as_of=&store_id=",
+					"description": "Stock quantity and cost value per product at a date, FIFO or average cost (managers only)",
+				},
+				"sales": {
+					"method": "GET",
+					"path":   "/api/reports/sales?granularity={hour|day|week|month}&from=&to=&store_id=",
+					"description": "Revenue, transactions and average basket per period, by business day of STORE_TIMEZONE and BUSINESS_DAY_CUTOFF (managers only)",
+				},
+				"products": {
+					"method": "GET",
+					"path":   "/api/reports/products?from=&to=&sort_by={quantity|revenue|profit}&order={top|bottom}&limit=10&store_id=&category_id=",
+					"description": "Best or slowest selling products by quantity, revenue or gross profit (managers only)",
+				},
+				"categories": {
+					"method": "GET",
+					"path":   "/api/reports/categories?from=&to=&sort_by={quantity|revenue|profit}&order={top|bottom}&limit=10&store_id=",
+					"description": "Best or slowest selling categories by quantity, revenue or gross profit (managers only)",
+				},
+				"dead-stock": {
+					"method": "GET",
+					"path":   "/api/reports/dead-stock?days=30&store_id=",
+					"description": "Products with stock but no sales in the given days, by stock value (managers only)",
+				},
+				"stores": {
+					"method": "GET",
+					"path":   "/api/reports/stores?from=&to=",
+					"description": "Sales, COGS, gross profit and average sale per store side by side (managers only)",
+				},
+				"expiring": {
+					"method": "GET",
+					"path":   "/api/reports/expiring?days=30",
+					"description": "Lots with stock that expire within the given days, expired lots included (managers only)",
+				},
+				"modifiers": {
+					"method": "GET",
+					"path":   "/api/reports/modifiers?from=&to=",
+					"description": "Modifier popularity by units sold (managers only)",
+				},
+			},
 			"Health": {
 				"check": {
 					"method": "GET",
@@ -149,10 +941,37 @@ func main() {
 		})
 	})
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	//release expired stock reservations in the background
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		reservationService.RunSweeper(ctx, env.ReservationSweepInterval)
+	}()
+
+	server := &http.Server{Addr: ":" + env.Port, Handler: middleware.RequestID(middleware.StoreID(http.DefaultServeMux))}
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
 	fmt.Println("Server running on localhost:" + env.Port)
 
-	err = http.ListenAndServe(":" + env.Port, nil)
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		fmt.Println(err)
 	}
+
+	stop()
+	<-shutdownDone
+	<-sweeperDone
+	fmt.Println("Server stopped")
 }
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+// AnyMethod in Roles applies to every method not listed on its own.
+const AnyMethod = "*"
+
+// Roles lists, per HTTP method, the roles allowed to call a route. Methods
+// without an entry are open to every signed-in user.
+type Roles map[string][]string
+
+type Auth struct {
+	service *services.AuthService
+}
+
+func NewAuth(service *services.AuthService) *Auth {
+	return &Auth{service: service}
+}
+
+// Protect requires a valid bearer token whose role is allowed for the
+// request method, and makes the token's claims available to next. Only
+// owners and managers choose the store they act for; everyone else acts
+// for the store they work at.
+func (a *Auth) Protect(next http.HandlerFunc, roles Roles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.service.ParseToken(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		allowed, listed := roles[r.Method]
+		if !listed {
+			allowed, listed = roles[AnyMethod]
+		}
+		if listed && !slices.Contains(allowed, claims.Role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := r.Context()
+		if claims.Role != models.RoleOwner && claims.Role != models.RoleManager {
+			if id := StoreIDFromContext(ctx); id != 0 && id != claims.StoreID {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			ctx = withStoreID(ctx, claims.StoreID)
+		}
+
+		ctx = context.WithValue(ctx, claimsKey, claims)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// ClaimsFromContext returns the claims of the signed-in user, if any.
+func ClaimsFromContext(ctx context.Context) (*models.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*models.Claims)
+	return claims, ok
+}
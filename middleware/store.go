@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const storeIDKey contextKey = "store_id"
+
+// StoreID reads the store a request acts for from the X-Store-ID header.
+// Requests without it act for the default store. Protect holds cashiers to
+// the store they work at.
+func StoreID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := 0
+		if v := r.Header.Get("X-Store-ID"); v != "" {
+			var err error
+			id, err = strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid X-Store-ID", http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(withStoreID(r.Context(), id)))
+	})
+}
+
+// withStoreID makes id the store a request acts for.
+func withStoreID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, storeIDKey, id)
+}
+
+func StoreIDFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(storeIDKey).(int)
+	return id
+}
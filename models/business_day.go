@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// BusinessDay says when a trading day starts: at Cutoff past midnight in
+// the store's time zone. Sales rung up before the cutoff, say at 02:00 in a
+// café closing at 03:00, count towards the previous day.
+type BusinessDay struct {
+	Location *time.Location
+	Cutoff   time.Duration
+}
+
+// NewBusinessDay reads a time zone name such as Asia/Jakarta, empty for the
+// server's, and a cutoff written HH:MM, empty for midnight.
+func NewBusinessDay(timezone, cutoff string) (BusinessDay, error) {
+	day := BusinessDay{Location: time.Local}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return day, fmt.Errorf("invalid time zone %q: %v", timezone, err)
+		}
+		day.Location = loc
+	}
+	if cutoff != "" {
+		at, err := time.Parse("15:04", cutoff)
+		if err != nil {
+			return day, fmt.Errorf("invalid business day cutoff %q, use HH:MM", cutoff)
+		}
+		day.Cutoff = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	}
+	return day, nil
+}
+
+// Zone is the IANA name of the time zone, or "" when it is the server's
+// local zone.
+func (d BusinessDay) Zone() string {
+	if d.Location == time.Local {
+		return ""
+	}
+	return d.Location.String()
+}
+
+// Date is the business date t falls on, as midnight in the store's time
+// zone.
+func (d BusinessDay) Date(t time.Time) time.Time {
+	t = t.In(d.Location).Add(-d.Cutoff)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, d.Location)
+}
+
+// Start is the moment the business day of date, read as a calendar date in
+// the store's time zone, opens.
+func (d BusinessDay) Start(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, d.Location).Add(d.Cutoff)
+}
+
+// Bounds is the half-open range [start, end) of the business day t falls
+// in.
+func (d BusinessDay) Bounds(t time.Time) (time.Time, time.Time) {
+	date := d.Date(t)
+	return d.Start(date), d.Start(date.AddDate(0, 0, 1))
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewBusinessDay(t *testing.T) {
+	tests := []struct {
+		timezone, cutoff string
+		want             time.Duration
+		ok               bool
+	}{
+		{"", "", 0, true},
+		{"", "03:00", 3 * time.Hour, true},
+		{"UTC", "04:30", 4*time.Hour + 30*time.Minute, true},
+		{"", "3am", 0, false},
+		{"", "25:00", 0, false},
+		{"Not/AZone", "", 0, false},
+	}
+	for _, tt := range tests {
+		day, err := NewBusinessDay(tt.timezone, tt.cutoff)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewBusinessDay(%q, %q) error = %v, want ok %v", tt.timezone, tt.cutoff, err, tt.ok)
+			continue
+		}
+		if tt.ok && day.Cutoff != tt.want {
+			t.Errorf("NewBusinessDay(%q, %q) cutoff = %v, want %v", tt.timezone, tt.cutoff, day.Cutoff, tt.want)
+		}
+	}
+}
+
+func TestBusinessDayBounds(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	tests := []struct {
+		name       string
+		day        BusinessDay
+		at         time.Time
+		date       string
+		start, end time.Time
+	}{
+		{
+			"midnight cutoff",
+			BusinessDay{Location: wib},
+			time.Date(2026, 3, 7, 10, 0, 0, 0, wib),
+			"2026-03-07",
+			time.Date(2026, 3, 7, 0, 0, 0, 0, wib),
+			time.Date(2026, 3, 8, 0, 0, 0, 0, wib),
+		},
+		{
+			"before the cutoff counts to the day before",
+			BusinessDay{Location: wib, Cutoff: 3 * time.Hour},
+			time.Date(2026, 3, 7, 2, 59, 0, 0, wib),
+			"2026-03-06",
+			time.Date(2026, 3, 6, 3, 0, 0, 0, wib),
+			time.Date(2026, 3, 7, 3, 0, 0, 0, wib),
+		},
+		{
+			"at the cutoff starts the new day",
+			BusinessDay{Location: wib, Cutoff: 3 * time.Hour},
+			time.Date(2026, 3, 7, 3, 0, 0, 0, wib),
+			"2026-03-07",
+			time.Date(2026, 3, 7, 3, 0, 0, 0, wib),
+			time.Date(2026, 3, 8, 3, 0, 0, 0, wib),
+		},
+		{
+			"UTC instant read in the store's zone",
+			BusinessDay{Location: wib},
+			time.Date(2026, 3, 6, 18, 30, 0, 0, time.UTC),
+			"2026-03-07",
+			time.Date(2026, 3, 7, 0, 0, 0, 0, wib),
+			time.Date(2026, 3, 8, 0, 0, 0, 0, wib),
+		},
+		{
+			"UTC instant before the cutoff in the store's zone",
+			BusinessDay{Location: wib, Cutoff: 3 * time.Hour},
+			time.Date(2026, 3, 6, 19, 30, 0, 0, time.UTC),
+			"2026-03-06",
+			time.Date(2026, 3, 6, 3, 0, 0, 0, wib),
+			time.Date(2026, 3, 7, 3, 0, 0, 0, wib),
+		},
+		{
+			"across a month end",
+			BusinessDay{Location: time.UTC, Cutoff: 4 * time.Hour},
+			time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC),
+			"2026-02-28",
+			time.Date(2026, 2, 28, 4, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.day.Date(tt.at).Format("2006-01-02"); got != tt.date {
+				t.Errorf("Date(%v) = %s, want %s", tt.at, got, tt.date)
+			}
+			start, end := tt.day.Bounds(tt.at)
+			if !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Errorf("Bounds(%v) = %v, %v, want %v, %v", tt.at, start, end, tt.start, tt.end)
+			}
+			if tt.at.Before(start) || !tt.at.Before(end) {
+				t.Errorf("%v is outside its own business day [%v, %v)", tt.at, start, end)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CartStatusOpen       = "open"
+	CartStatusHeld       = "held"
+	CartStatusCheckedOut = "checked_out"
+)
+
+type Cart struct {
+	ID            int            `json:"id"`
+	Status        string         `json:"status"`
+	Note          string         `json:"note"`
+	Items         []CheckoutItem `json:"items"`
+	ReservedUntil *time.Time     `json:"reserved_until,omitempty"`
+	TransactionID *int           `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type HoldCartRequest struct {
+	Note           string `json:"note"`
+	ReserveMinutes int    `json:"reserve_minutes"`
+	// StoreID comes from the X-Store-ID header.
+	StoreID int `json:"-"`
+}
+
+// ReservationRef is the reference the cart's stock reservations are
+// recorded under.
+func (c Cart) ReservationRef() string {
+	return fmt.Sprintf("cart:%d", c.ID)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type Customer struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CustomerHistory struct {
+	Customer      Customer      `json:"customer"`
+	LifetimeSpend int           `json:"lifetime_spend"`
+	VisitCount    int           `json:"visit_count"`
+	FirstVisit    *time.Time    `json:"first_visit,omitempty"`
+	LastVisit     *time.Time    `json:"last_visit,omitempty"`
+	Transactions  []Transaction `json:"transactions"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	PaymentMethodGiftCard = "gift_card"
+
+	GiftCardStatusActive = "active"
+	GiftCardStatusVoid   = "void"
+
+	GiftCardIssue  = "issue"
+	GiftCardRedeem = "redeem"
+	GiftCardRefund = "refund"
+	GiftCardVoid   = "void"
+)
+
+// GiftCard is stored value spendable at checkout with the gift_card payment
+// method, the code going in the payment reference. A card issued to a
+// customer doubles as store credit.
+type GiftCard struct {
+	ID             int                   `json:"id"`
+	Code           string                `json:"code"`
+	InitialBalance int                   `json:"initial_balance"`
+	Balance        int                   `json:"balance"`
+	Status         string                `json:"status"`
+	CustomerID     int                   `json:"customer_id,omitempty"`
+	ExpiresAt      *time.Time            `json:"expires_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	Ledger         []GiftCardLedgerEntry `json:"ledger,omitempty"`
+}
+
+type GiftCardLedgerEntry struct {
+	ID            int       `json:"id"`
+	GiftCardID    int       `json:"gift_card_id"`
+	TransactionID *int      `json:"transaction_id,omitempty"`
+	Type          string    `json:"type"`
+	Amount        int       `json:"amount"`
+	BalanceAfter  int       `json:"balance_after"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IssueGiftCardRequest issues a card. PaidCash says the card was sold for
+// cash, which then goes into the drawer of the issuer's open shift.
+type IssueGiftCardRequest struct {
+	Code       string     `json:"code"`
+	Amount     int        `json:"amount"`
+	CustomerID int        `json:"customer_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	PaidCash   bool       `json:"paid_cash"`
+}
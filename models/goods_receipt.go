@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// GoodsReceipt records stock arriving from a supplier. Posting it adds the
+// quantities to stock and sets each product's cost price. Receipts made
+// against a purchase order carry its id and supplier.
+type GoodsReceipt struct {
+	ID              int                `json:"id"`
+	PurchaseOrderID int                `json:"purchase_order_id,omitempty"`
+	SupplierID      int                `json:"supplier_id,omitempty"`
+	StoreID         int                `json:"store_id,omitempty"`
+	Reference       string             `json:"reference"`
+	Supplier        string             `json:"supplier"`
+	Note            string             `json:"note"`
+	ReceivedBy      int                `json:"received_by,omitempty"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	TotalCost       int                `json:"total_cost"`
+	Items           []GoodsReceiptItem `json:"items"`
+}
+
+// GoodsReceiptItem is one received line. Lot-tracked products need a lot
+// number and may carry an expiry date (YYYY-MM-DD).
+type GoodsReceiptItem struct {
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name,omitempty"`
+	VariantID   int    `json:"variant_id,omitempty"`
+	Quantity    int    `json:"quantity"`
+	CostPrice   int    `json:"cost_price"`
+	LotNumber   string `json:"lot_number,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+}
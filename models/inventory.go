@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	StockMovementOpening     = "opening"
+	StockMovementReceipt     = "receipt"
+	StockMovementSale        = "sale"
+	StockMovementRefund      = "refund"
+	StockMovementAdjustment  = "adjustment"
+	StockMovementRevaluation = "revaluation"
+	StockMovementStocktake   = "stocktake"
+	StockMovementTransferOut = "transfer_out"
+	StockMovementTransferIn  = "transfer_in"
+)
+
+// InventoryValuation is the quantity and cost value of stock on hand at a
+// point in time, under the configured costing method.
+type InventoryValuation struct {
+	AsOf          time.Time       `json:"as_of"`
+	Method        string          `json:"method"`
+	TotalQuantity int             `json:"total_quantity"`
+	TotalValue    int             `json:"total_value"`
+	Lines         []ValuationLine `json:"lines"`
+}
+
+type ValuationLine struct {
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Value     int    `json:"value"`
+	UnitCost  int    `json:"unit_cost"`
+}
+
+// DeadStockLine is a product or variant with stock that has not sold, on
+// its own or as a component, for the period asked for. Value is the stock
+// at cost price.
+type DeadStockLine struct {
+	ProductID  int        `json:"product_id"`
+	VariantID  int        `json:"variant_id,omitempty"`
+	Name       string     `json:"name"`
+	Stock      int        `json:"stock"`
+	Value      int        `json:"value"`
+	LastSoldAt *time.Time `json:"last_sold_at"`
+}
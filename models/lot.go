@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ProductLot is a batch of a lot-tracked product or variant received with
+// a lot number and, optionally, an expiry date (YYYY-MM-DD). Stock received
+// without a lot, such as stock that predates lot tracking, sits in a lot
+// with an empty number.
+type ProductLot struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	VariantID  int       `json:"variant_id,omitempty"`
+	StoreID    int       `json:"store_id,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	LotNumber  string    `json:"lot_number"`
+	ExpiresAt  string    `json:"expires_at,omitempty"`
+	Quantity   int       `json:"quantity"`
+	ReceivedAt time.Time `json:"received_at"`
+	DaysLeft   *int      `json:"days_left,omitempty"`
+	Value      int       `json:"value,omitempty"`
+}
+
+// DetailLot is the lot a transaction line, or one of its components, was
+// sold from.
+type DetailLot struct {
+	ProductID int    `json:"product_id"`
+	LotID     int    `json:"lot_id"`
+	LotNumber string `json:"lot_number"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	PaymentMethodPoints = "points"
+
+	LoyaltyEarn     = "earn"
+	LoyaltyRedeem   = "redeem"
+	LoyaltyClawback = "clawback"
+	LoyaltyRefund   = "refund"
+)
+
+// LoyaltyRules decide how many points a sale earns and what a point is
+// worth when redeemed.
+type LoyaltyRules struct {
+	Enabled             bool                 `json:"enabled"`
+	RupiahPerPoint      int                  `json:"rupiah_per_point"`
+	PointValue          int                  `json:"point_value"`
+	CategoryMultipliers []CategoryMultiplier `json:"category_multipliers"`
+}
+
+type CategoryMultiplier struct {
+	CategoryID int     `json:"category_id"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+type LoyaltyLedgerEntry struct {
+	ID            int       `json:"id"`
+	CustomerID    int       `json:"customer_id"`
+	TransactionID *int      `json:"transaction_id,omitempty"`
+	Type          string    `json:"type"`
+	Points        int       `json:"points"`
+	BalanceAfter  int       `json:"balance_after"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type LoyaltyAccount struct {
+	CustomerID int                  `json:"customer_id"`
+	Balance    int                  `json:"balance"`
+	PointValue int                  `json:"point_value"`
+	Ledger     []LoyaltyLedgerEntry `json:"ledger"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// ModifierGroup is a set of options a product can be customised with,
+// such as "Sugar level" or "Toppings". A required group needs at least
+// one selection; MaxSelect of 0 means no upper limit.
+type ModifierGroup struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Required   bool       `json:"required"`
+	MinSelect  int        `json:"min_select"`
+	MaxSelect  int        `json:"max_select"`
+	Modifiers  []Modifier `json:"modifiers"`
+	ProductIDs []int      `json:"product_ids"`
+}
+
+type Modifier struct {
+	ID         int    `json:"id"`
+	GroupID    int    `json:"group_id"`
+	Name       string `json:"name"`
+	PriceDelta int    `json:"price_delta"`
+}
+
+// DetailModifier is a modifier as it was sold on a transaction line, with
+// the name and price at the time of sale.
+type DetailModifier struct {
+	ModifierID int    `json:"modifier_id"`
+	Name       string `json:"name"`
+	PriceDelta int    `json:"price_delta"`
+}
+
+type ModifierPopularity struct {
+	ModifierID int    `json:"modifier_id"`
+	Name       string `json:"name"`
+	GroupName  string `json:"group_name"`
+	Quantity   int    `json:"quantity"`
+	Revenue    int    `json:"revenue"`
+}
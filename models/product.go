@@ -1,9 +1,50 @@
 package models
 
 type Product struct {
-	ID         int    `json:"id"`
-	Name       string `json:"name"`
-	Price      int    `json:"price"`
-	Stock      int    `json:"stock"`
-	CategoryID int    `json:"category_id"`
+	ID             int                `json:"id"`
+	Name           string             `json:"name"`
+	Price          int                `json:"price"`
+	CostPrice      int                `json:"cost_price"`
+	Stock          int                `json:"stock"`
+	AvailableStock int                `json:"available_stock"`
+	CategoryID     int                `json:"category_id"`
+	LotTracked     bool               `json:"lot_tracked"`
+	Variants       []ProductVariant   `json:"variants,omitempty"`
+	ModifierGroups []ModifierGroup    `json:"modifier_groups,omitempty"`
+	Components     []ProductComponent `json:"components,omitempty"`
+}
+
+// ProductComponent is one line of a composite product's recipe: selling
+// one unit of the product uses Quantity units of the component. A
+// composite product has no stock of its own; its stock and cost are
+// worked out from its components.
+type ProductComponent struct {
+	ComponentID    int    `json:"component_id"`
+	Name           string `json:"name"`
+	Quantity       int    `json:"quantity"`
+	CostPrice      int    `json:"cost_price"`
+	AvailableStock int    `json:"available_stock"`
+}
+
+// DetailComponent is the component stock a composite transaction line
+// used.
+type DetailComponent struct {
+	ComponentID int    `json:"component_id"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
+}
+
+// ProductVariant is one sellable option of a parent product, such as a
+// size or colour, with its own SKU, price and stock. Sales of a variant
+// are recorded against the parent product id so reports roll up.
+type ProductVariant struct {
+	ID             int               `json:"id"`
+	ProductID      int               `json:"product_id"`
+	SKU            string            `json:"sku"`
+	Name           string            `json:"name"`
+	Options        map[string]string `json:"options"`
+	Price          int               `json:"price"`
+	CostPrice      int               `json:"cost_price"`
+	Stock          int               `json:"stock"`
+	AvailableStock int               `json:"available_stock"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type StockReservation struct {
+	ID         int        `json:"id"`
+	ProductID  int        `json:"product_id"`
+	VariantID  int        `json:"variant_id,omitempty"`
+	StoreID    int        `json:"store_id,omitempty"`
+	Quantity   int        `json:"quantity"`
+	Reference  string     `json:"reference"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ReservationRequest struct {
+	Reference  string         `json:"reference"`
+	Items      []CheckoutItem `json:"items"`
+	TTLMinutes int            `json:"ttl_minutes"`
+	// StoreID comes from the X-Store-ID header.
+	StoreID int `json:"-"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+const (
+	ShiftStatusOpen   = "open"
+	ShiftStatusClosed = "closed"
+
+	CashMovementIn  = "cash_in"
+	CashMovementOut = "cash_out"
+)
+
+type Shift struct {
+	ID           int        `json:"id"`
+	CashierID    int        `json:"cashier_id"`
+	CashierName  string     `json:"cashier_name"`
+	RegisterID   string     `json:"register_id,omitempty"`
+	Status       string     `json:"status"`
+	OpeningCash  int        `json:"opening_cash"`
+	ExpectedCash *int       `json:"expected_cash,omitempty"`
+	CountedCash  *int       `json:"counted_cash,omitempty"`
+	Variance     *int       `json:"variance,omitempty"`
+	Note         string     `json:"note"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	// BusinessDate is the business day the shift was opened on, which a
+	// shift running past midnight but not past the cutoff stays on.
+	BusinessDate string `json:"business_date"`
+}
+
+type CashMovement struct {
+	ID        int       `json:"id"`
+	ShiftID   int       `json:"shift_id"`
+	Type      string    `json:"type"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OpenShiftRequest struct {
+	OpeningCash int    `json:"opening_cash"`
+	Note        string `json:"note"`
+}
+
+type CloseShiftRequest struct {
+	CountedCash int    `json:"counted_cash"`
+	Note        string `json:"note"`
+}
+
+type PaymentSummary struct {
+	Method string `json:"method"`
+	Amount int    `json:"amount"`
+	Count  int    `json:"count"`
+}
+
+// ZReport summarises a shift for end-of-day reconciliation.
+type ZReport struct {
+	Shift            Shift            `json:"shift"`
+	TransactionCount int              `json:"transaction_count"`
+	TotalSales       int              `json:"total_sales"`
+	Payments         []PaymentSummary `json:"payments"`
+	CashSales        int              `json:"cash_sales"`
+	ChangeGiven      int              `json:"change_given"`
+	CashIn           int              `json:"cash_in"`
+	CashOut          int              `json:"cash_out"`
+	ExpectedCash     int              `json:"expected_cash"`
+	CountedCash      *int             `json:"counted_cash,omitempty"`
+	Variance         *int             `json:"variance,omitempty"`
+	CashMovements    []CashMovement   `json:"cash_movements"`
+}
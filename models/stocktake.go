@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusApproved  = "approved"
+	StocktakeStatusCancelled = "cancelled"
+
+	StocktakeCountSet = "set"
+	StocktakeCountAdd = "add"
+)
+
+// Stocktake is a physical count session. Expected quantities are
+// snapshotted when it starts and again when each line is counted; on
+// approval each counted line posts its variance on top of the current
+// stock, so sales made during the count are kept.
+type Stocktake struct {
+	ID            int             `json:"id"`
+	Status        string          `json:"status"`
+	Note          string          `json:"note"`
+	CategoryID    int             `json:"category_id,omitempty"`
+	StoreID       int             `json:"store_id,omitempty"`
+	CreatedBy     int             `json:"created_by,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ClosedBy      int             `json:"closed_by,omitempty"`
+	ClosedAt      *time.Time      `json:"closed_at,omitempty"`
+	LineCount     int             `json:"line_count"`
+	CountedLines  int             `json:"counted_lines"`
+	VarianceValue int             `json:"variance_value"`
+	Lines         []StocktakeLine `json:"lines,omitempty"`
+}
+
+// StocktakeLine compares the snapshotted stock of a product or variant with
+// what was counted. VarianceValue is an estimate at cost price until the
+// stocktake is approved, then the value actually posted.
+type StocktakeLine struct {
+	ProductID     int        `json:"product_id"`
+	VariantID     int        `json:"variant_id,omitempty"`
+	Name          string     `json:"name"`
+	SKU           string     `json:"sku,omitempty"`
+	Expected      int        `json:"expected"`
+	Counted       *int       `json:"counted"`
+	Variance      int        `json:"variance"`
+	VarianceValue int        `json:"variance_value"`
+	CountedAt     *time.Time `json:"counted_at,omitempty"`
+}
+
+// StocktakeCount is one counted line. Scanners can send the variant SKU
+// instead of the ids.
+type StocktakeCount struct {
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+}
+
+// StocktakeCountRequest is a batch of counts. Mode "set" replaces the
+// counted quantity; "add" adds to it, for scanners sending one scan at a
+// time.
+type StocktakeCountRequest struct {
+	Mode  string           `json:"mode"`
+	Items []StocktakeCount `json:"items"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// Store is one outlet. Requests pick their store with the X-Store-ID
+// header; without it they act for the default store. The default store
+// is the first one created: it keeps the stock that was on hand before
+// there were stores and sells at the products' own prices.
+type Store struct {
+	ID        int    `json:"id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	Phone     string `json:"phone"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// StoreProduct is a product or variant's stock at one store, with the
+// store's price override if it has one. Price is the price the store sells
+// at.
+type StoreProduct struct {
+	StoreID       int    `json:"store_id"`
+	ProductID     int    `json:"product_id"`
+	VariantID     int    `json:"variant_id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Stock         int    `json:"stock"`
+	Price         int    `json:"price"`
+	PriceOverride *int   `json:"price_override"`
+}
+
+// StorePrice sets or, when Price is nil, clears a store's price for a
+// product or variant.
+type StorePrice struct {
+	ProductID int  `json:"product_id"`
+	VariantID int  `json:"variant_id,omitempty"`
+	Price     *int `json:"price"`
+}
+
+// StoreSales compares the outlets over a period.
+type StoreSales struct {
+	StoreID      int     `json:"store_id"`
+	StoreName    string  `json:"store_name"`
+	Transactions int     `json:"transactions"`
+	Revenue      int     `json:"revenue"`
+	COGS         int     `json:"cogs"`
+	GrossProfit  int     `json:"gross_profit"`
+	Margin       float64 `json:"margin"`
+	AverageSale  int     `json:"average_sale"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderClosed            = "closed"
+)
+
+type Supplier struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+	Note    string `json:"note"`
+}
+
+// SupplierProduct links a product or variant to a supplier who sells it.
+// LastCost is updated by every goods receipt from that supplier. When
+// stock falls to MinStock the product is suggested for reordering up to
+// MaxStock.
+type SupplierProduct struct {
+	SupplierID   int    `json:"supplier_id"`
+	SupplierName string `json:"supplier_name,omitempty"`
+	ProductID    int    `json:"product_id"`
+	VariantID    int    `json:"variant_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	SupplierSKU  string `json:"supplier_sku"`
+	LastCost     int    `json:"last_cost"`
+	MinStock     int    `json:"min_stock"`
+	MaxStock     int    `json:"max_stock"`
+	Stock        int    `json:"stock"`
+}
+
+// PurchaseOrder is an order placed with a supplier. It is edited as a
+// draft, sent, then received against in one or more goods receipts until
+// everything has arrived or it is closed short.
+type PurchaseOrder struct {
+	ID           int                 `json:"id"`
+	SupplierID   int                 `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name,omitempty"`
+	Status       string              `json:"status"`
+	Note         string              `json:"note"`
+	CreatedBy    int                 `json:"created_by,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	SentAt       *time.Time          `json:"sent_at,omitempty"`
+	ClosedAt     *time.Time          `json:"closed_at,omitempty"`
+	TotalCost    int                 `json:"total_cost"`
+	Items        []PurchaseOrderItem `json:"items"`
+}
+
+type PurchaseOrderItem struct {
+	ProductID        int    `json:"product_id"`
+	VariantID        int    `json:"variant_id,omitempty"`
+	Name             string `json:"name,omitempty"`
+	SupplierSKU      string `json:"supplier_sku,omitempty"`
+	Quantity         int    `json:"quantity"`
+	ReceivedQuantity int    `json:"received_quantity"`
+	UnitCost         int    `json:"unit_cost"`
+}
+
+type PurchaseOrderFilter struct {
+	SupplierID int
+	Status     string
+}
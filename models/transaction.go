@@ -3,32 +3,180 @@ package models
 import "time"
 
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount int                 `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details"`
+	ID            int                 `json:"id"`
+	ReceiptNumber string              `json:"receipt_number"`
+	StoreID       int                 `json:"store_id,omitempty"`
+	CashierID     int                 `json:"cashier_id,omitempty"`
+	CashierName   string              `json:"cashier_name,omitempty"`
+	RegisterID    string              `json:"register_id,omitempty"`
+	ShiftID       int                 `json:"shift_id,omitempty"`
+	CustomerID    int                 `json:"customer_id,omitempty"`
+	TotalAmount   int                 `json:"total_amount"`
+	PaidAmount    int                 `json:"paid_amount"`
+	ChangeAmount  int                 `json:"change_amount"`
+	CreatedAt     time.Time           `json:"created_at"`
+	RefundedAt    *time.Time          `json:"refunded_at,omitempty"`
+	Details       []TransactionDetail `json:"details"`
+	Payments      []Payment           `json:"payments"`
+	// PointsEarned and PointsRedeemed are only filled in on checkout.
+	PointsEarned   int `json:"points_earned,omitempty"`
+	PointsRedeemed int `json:"points_redeemed,omitempty"`
 }
 
 type TransactionDetail struct {
-	ID            int    `json:"id"`
-	TransactionID int    `json:"transaction_id"`
-	ProductID     int    `json:"product_id"`
-	ProductName   string `json:"product_name,omitempty"`
-	Quantity      int    `json:"quantity"`
-	Subtotal      int    `json:"subtotal"`
+	ID            int               `json:"id"`
+	TransactionID int               `json:"transaction_id"`
+	ProductID     int               `json:"product_id"`
+	ProductName   string            `json:"product_name,omitempty"`
+	VariantID     int               `json:"variant_id,omitempty"`
+	VariantName   string            `json:"variant_name,omitempty"`
+	Modifiers     []DetailModifier  `json:"modifiers,omitempty"`
+	Components    []DetailComponent `json:"components,omitempty"`
+	Lots          []DetailLot       `json:"lots,omitempty"`
+	Quantity      int               `json:"quantity"`
+	Subtotal      int               `json:"subtotal"`
+	// Cost is the cost of goods of the line, snapshotted at sale time.
+	Cost int `json:"cost"`
 }
 
+// CheckoutItem is one line of a sale. VariantID is required when the
+// product has variants; ModifierIDs are the selected modifiers, each
+// priced into the line.
 type CheckoutItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID   int   `json:"product_id"`
+	VariantID   int   `json:"variant_id,omitempty"`
+	ModifierIDs []int `json:"modifier_ids,omitempty"`
+	Quantity    int   `json:"quantity"`
+}
+
+const (
+	PaymentMethodCash = "cash"
+	PaymentMethodCard = "card"
+	PaymentMethodQRIS = "qris"
+)
+
+type Payment struct {
+	Method    string `json:"method"`
+	Amount    int    `json:"amount"`
+	Reference string `json:"reference,omitempty"`
 }
 
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items"`
+	Items          []CheckoutItem `json:"items"`
+	Payments       []Payment      `json:"payments"`
+	CustomerID     int            `json:"customer_id,omitempty"`
+	ReservationRef string         `json:"reservation_ref,omitempty"`
+	// CashierID, RegisterID and Actor come from the signed-in user and
+	// request headers, never from the request body.
+	CashierID  int    `json:"-"`
+	RegisterID string `json:"-"`
+	Actor      Actor  `json:"-"`
+	// CartID rings up a parked cart: its items and reservation replace
+	// Items and ReservationRef, and the cart is closed with the sale.
+	CartID int `json:"-"`
+}
+
+type TransactionFilter struct {
+	StoreID    int
+	CashierID  int
+	CustomerID int
+	RegisterID string
+	From       time.Time
+	To         time.Time
+	Limit      int
 }
 
 type Report struct {
-	TotalRevenue int `json:"total_revenue"`
-	TotalSales   int `json:"total_sales"`
-	BestSeller   int `json:"best_seller"`
+	TotalRevenue int             `json:"total_revenue"`
+	TotalSales   int             `json:"total_sales"`
+	BestSeller   int             `json:"best_seller"`
+	Cashiers     []CashierReport `json:"cashiers"`
+}
+
+type CashierReport struct {
+	CashierID    int    `json:"cashier_id"`
+	CashierName  string `json:"cashier_name"`
+	TotalRevenue int    `json:"total_revenue"`
+	TotalSales   int    `json:"total_sales"`
+}
+
+// ProfitReport is revenue against cost of goods over a period, broken down
+// by product, category or day.
+type ProfitReport struct {
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	GroupBy     string       `json:"group_by"`
+	Revenue     int          `json:"revenue"`
+	COGS        int          `json:"cogs"`
+	GrossProfit int          `json:"gross_profit"`
+	Margin      float64      `json:"margin"`
+	Lines       []ProfitLine `json:"lines"`
+}
+
+type ProfitLine struct {
+	Key         string  `json:"key"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	Revenue     int     `json:"revenue"`
+	COGS        int     `json:"cogs"`
+	GrossProfit int     `json:"gross_profit"`
+	Margin      float64 `json:"margin"`
+}
+
+// SalesReport is sales over a period in hour, day, week or month buckets
+// of the store's time zone. Weeks start on Monday.
+type SalesReport struct {
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	Granularity  string        `json:"granularity"`
+	TimeZone     string        `json:"time_zone"`
+	Revenue      int           `json:"revenue"`
+	Transactions int           `json:"transactions"`
+	AverageSale  int           `json:"average_sale"`
+	AverageItems float64       `json:"average_items"`
+	Buckets      []SalesBucket `json:"buckets"`
+}
+
+// SalesBucket is one period of a sales report. AverageSale is the average
+// basket value and AverageItems the average number of items per basket.
+type SalesBucket struct {
+	Start        time.Time `json:"start"`
+	Label        string    `json:"label"`
+	Revenue      int       `json:"revenue"`
+	Transactions int       `json:"transactions"`
+	Items        int       `json:"items"`
+	AverageSale  int       `json:"average_sale"`
+	AverageItems float64   `json:"average_items"`
+}
+
+// SalesRanking ranks products or categories by quantity, revenue or gross
+// profit over a period. Order "top" lists the best first, "bottom" the
+// slowest movers first, including those that sold nothing.
+type SalesRanking struct {
+	From   time.Time     `json:"from"`
+	To     time.Time     `json:"to"`
+	SortBy string        `json:"sort_by"`
+	Order  string        `json:"order"`
+	Lines  []RankingLine `json:"lines"`
+}
+
+type RankingLine struct {
+	Rank        int     `json:"rank"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	Revenue     int     `json:"revenue"`
+	COGS        int     `json:"cogs"`
+	GrossProfit int     `json:"gross_profit"`
+	Margin      float64 `json:"margin"`
+}
+
+type RankingFilter struct {
+	From       time.Time
+	To         time.Time
+	SortBy     string
+	Order      string
+	Limit      int
+	StoreID    int
+	CategoryID int
 }
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	TransferRequested = "requested"
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// StockTransfer moves stock from one store to another. It is requested,
+// dispatched, which takes the stock out of the source store, and received,
+// which books what actually arrived into the destination store.
+type StockTransfer struct {
+	ID            int                 `json:"id"`
+	FromStoreID   int                 `json:"from_store_id"`
+	FromStoreName string              `json:"from_store_name,omitempty"`
+	ToStoreID     int                 `json:"to_store_id"`
+	ToStoreName   string              `json:"to_store_name,omitempty"`
+	Status        string              `json:"status"`
+	Note          string              `json:"note"`
+	RequestedBy   int                 `json:"requested_by,omitempty"`
+	RequestedAt   time.Time           `json:"requested_at"`
+	DispatchedBy  int                 `json:"dispatched_by,omitempty"`
+	DispatchedAt  *time.Time          `json:"dispatched_at,omitempty"`
+	ReceivedBy    int                 `json:"received_by,omitempty"`
+	ReceivedAt    *time.Time          `json:"received_at,omitempty"`
+	ReceivedNote  string              `json:"received_note,omitempty"`
+	Items         []StockTransferItem `json:"items"`
+}
+
+// StockTransferItem is one line of a transfer. Cost is the value that left
+// the source store on dispatch. Discrepancy is received minus dispatched,
+// negative when goods went missing on the way.
+type StockTransferItem struct {
+	ProductID          int    `json:"product_id"`
+	VariantID          int    `json:"variant_id,omitempty"`
+	Name               string `json:"name,omitempty"`
+	Quantity           int    `json:"quantity"`
+	DispatchedQuantity int    `json:"dispatched_quantity"`
+	ReceivedQuantity   int    `json:"received_quantity"`
+	Discrepancy        int    `json:"discrepancy"`
+	Cost               int    `json:"cost"`
+}
+
+// TransferReceipt records what arrived. Lines left out arrived in full.
+type TransferReceipt struct {
+	Items []TransferReceiptItem `json:"items"`
+	Note  string                `json:"note"`
+}
+
+type TransferReceiptItem struct {
+	ProductID int `json:"product_id"`
+	VariantID int `json:"variant_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type StockTransferFilter struct {
+	StoreID int
+	Status  string
+}
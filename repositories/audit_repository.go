@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"kasir-api/models"
+	"strings"
+)
+
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditCheckout = "checkout"
+	AuditRefund   = "refund"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) GetAll(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if filter.EntityID != 0 {
+		args = append(args, filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if filter.ActorID != 0 {
+		args = append(args, filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := "SELECT id, COALESCE(actor_id, 0), actor_name, action, entity_type, entity_id, before, after, request_id, created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]models.AuditEntry, 0)
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// writeAudit appends an audit_log row inside tx, so the entry commits or
+// rolls back together with the change it describes. before and after are
+// stored as JSON snapshots; pass nil when there is none.
+func writeAudit(tx *sql.Tx, actor models.Actor, action, entityType string, entityID int, before, after interface{}) error {
+	beforeJSON, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor_id, actor_name, action, entity_type, entity_id, before, after, request_id)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.Exec(query, actor.UserID, actor.Username, action, entityType, entityID, beforeJSON, afterJSON, actor.RequestID)
+	return err
+}
+
+func auditSnapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,462 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CartRepository struct {
+	db *sql.DB
+}
+
+func NewCartRepository(db *sql.DB) *CartRepository {
+	return &CartRepository{db: db}
+}
+
+func (r *CartRepository) GetAll(status string) ([]models.Cart, error) {
+	query := "SELECT id, status, note, reserved_until, transaction_id, created_at, updated_at FROM carts"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY updated_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var carts []models.Cart
+	for rows.Next() {
+		var cart models.Cart
+		err := rows.Scan(&cart.ID, &cart.Status, &cart.Note, &cart.ReservedUntil, &cart.TransactionID, &cart.CreatedAt, &cart.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		carts = append(carts, cart)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range carts {
+		carts[i].Items, err = getItems(r.db, carts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return carts, nil
+}
+
+func (r *CartRepository) GetByID(id int) (*models.Cart, error) {
+	return getCart(r.db, id, false)
+}
+
+// getCart reads a cart with its items, locking the cart row when lock is
+// true.
+func getCart(q querier, id int, lock bool) (*models.Cart, error) {
+	query := "SELECT id, status, note, reserved_until, transaction_id, created_at, updated_at FROM carts WHERE id = $1"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var cart models.Cart
+	err := q.QueryRow(query, id).Scan(&cart.ID, &cart.Status, &cart.Note, &cart.ReservedUntil, &cart.TransactionID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("cart not found")
+		}
+		return nil, err
+	}
+
+	cart.Items, err = getItems(q, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cart, nil
+}
+
+// commitCart records a change to a cart in the audit log and commits it.
+func commitCart(tx *sql.Tx, id int, actor models.Actor, before *models.Cart) error {
+	after, err := getCart(tx, id, false)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "cart", id, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getItems(q querier, cartID int) ([]models.CheckoutItem, error) {
+	rows, err := q.Query("SELECT product_id, variant_id, modifier_ids, quantity FROM cart_items WHERE cart_id = $1 ORDER BY created_at", cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.CheckoutItem, 0)
+	for rows.Next() {
+		var item models.CheckoutItem
+		var modifiers string
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &modifiers, &item.Quantity); err != nil {
+			return nil, err
+		}
+		for _, id := range strings.Split(modifiers, ",") {
+			if id == "" {
+				continue
+			}
+			modifierID, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			item.ModifierIDs = append(item.ModifierIDs, modifierID)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (r *CartRepository) Create(cart *models.Cart, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO carts (status, note) VALUES ($1, $2) RETURNING id, created_at, updated_at"
+	err = tx.QueryRow(query, models.CartStatusOpen, cart.Note).Scan(&cart.ID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	cart.Status = models.CartStatusOpen
+
+	for _, item := range cart.Items {
+		if err := addItem(tx, cart.ID, item); err != nil {
+			return err
+		}
+	}
+	if cart.Items == nil {
+		cart.Items = make([]models.CheckoutItem, 0)
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "cart", cart.ID, nil, cart); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *CartRepository) AddItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenCart(tx, cartID)
+	if err != nil {
+		return err
+	}
+	if err := addItem(tx, cartID, item); err != nil {
+		return err
+	}
+
+	return commitCart(tx, cartID, actor, before)
+}
+
+func (r *CartRepository) UpdateItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	if item.Quantity <= 0 {
+		return r.RemoveItem(cartID, item, actor)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenCart(tx, cartID)
+	if err != nil {
+		return err
+	}
+
+	if err := resolveVariantProduct(tx, &item); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE cart_items SET quantity = $1 WHERE cart_id = $2 AND product_id = $3 AND variant_id = $4 AND modifier_ids = $5",
+		item.Quantity, cartID, item.ProductID, item.VariantID, modifierKey(item.ModifierIDs))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("cart item not found")
+	}
+
+	return commitCart(tx, cartID, actor, before)
+}
+
+// RemoveItem drops the cart line matching item's product, variant and
+// modifiers.
+func (r *CartRepository) RemoveItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenCart(tx, cartID)
+	if err != nil {
+		return err
+	}
+
+	if err := resolveVariantProduct(tx, &item); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND variant_id = $3 AND modifier_ids = $4",
+		cartID, item.ProductID, item.VariantID, modifierKey(item.ModifierIDs))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("cart item not found")
+	}
+
+	return commitCart(tx, cartID, actor, before)
+}
+
+// Hold parks an open cart. When reserveFor is positive the cart's items are
+// reserved until the deadline so other sales cannot take them.
+func (r *CartRepository) Hold(cartID int, note string, reserveFor time.Duration, storeID int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenCart(tx, cartID)
+	if err != nil {
+		return err
+	}
+
+	var reservedUntil *time.Time
+	if reserveFor > 0 {
+		items, err := getItems(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		until := time.Now().Add(reserveFor)
+		_, err = reserveStock(tx, models.Cart{ID: cartID}.ReservationRef(), items, until, storeID)
+		if err != nil {
+			return err
+		}
+		reservedUntil = &until
+	}
+
+	_, err = tx.Exec("UPDATE carts SET status = $1, note = COALESCE(NULLIF($2, ''), note), reserved_until = $3, updated_at = NOW() WHERE id = $4",
+		models.CartStatusHeld, note, reservedUntil, cartID)
+	if err != nil {
+		return err
+	}
+
+	return commitCart(tx, cartID, actor, before)
+}
+
+// Resume reopens a held cart and drops any reservation it had.
+func (r *CartRepository) Resume(cartID int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getCart(tx, cartID, true)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE carts SET status = $1, reserved_until = NULL, updated_at = NOW() WHERE id = $2 AND status = $3",
+		models.CartStatusOpen, cartID, models.CartStatusHeld)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("held cart not found")
+	}
+
+	if err := releaseReservations(tx, models.Cart{ID: cartID}.ReservationRef()); err != nil {
+		return err
+	}
+
+	return commitCart(tx, cartID, actor, before)
+}
+
+// lockCartForCheckout locks a cart that has not been checked out yet and
+// returns it with its items.
+func lockCartForCheckout(tx *sql.Tx, cartID int) (*models.Cart, error) {
+	cart, err := getCart(tx, cartID, true)
+	if err != nil {
+		return nil, err
+	}
+	if cart.Status == models.CartStatusCheckedOut {
+		return nil, fmt.Errorf("cart %d is already checked out", cartID)
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cart %d is empty", cartID)
+	}
+	return cart, nil
+}
+
+// markCheckedOut closes a cart locked by lockCartForCheckout with the
+// transaction it was sold in.
+func markCheckedOut(tx *sql.Tx, before *models.Cart, transactionID int, actor models.Actor) error {
+	result, err := tx.Exec("UPDATE carts SET status = $1, reserved_until = NULL, transaction_id = $2, updated_at = NOW() WHERE id = $3 AND status <> $1",
+		models.CartStatusCheckedOut, transactionID, before.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != 1 {
+		return fmt.Errorf("cart %d is already checked out", before.ID)
+	}
+
+	after, err := getCart(tx, before.ID, false)
+	if err != nil {
+		return err
+	}
+	return writeAudit(tx, actor, AuditUpdate, "cart", before.ID, before, after)
+}
+
+func (r *CartRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getCart(tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM carts WHERE id = $1 AND status <> $2"
+	result, err := tx.Exec(query, id, models.CartStatusCheckedOut)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("cart not found")
+	}
+
+	if err := releaseReservations(tx, models.Cart{ID: id}.ReservationRef()); err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "cart", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockOpenCart locks an open cart for a change and returns it as it was
+// before.
+func lockOpenCart(tx *sql.Tx, cartID int) (*models.Cart, error) {
+	cart, err := getCart(tx, cartID, true)
+	if err != nil {
+		return nil, err
+	}
+	if cart.Status != models.CartStatusOpen {
+		return nil, fmt.Errorf("cart is %s, only open carts can be changed", cart.Status)
+	}
+
+	_, err = tx.Exec("UPDATE carts SET updated_at = NOW() WHERE id = $1", cartID)
+	return cart, err
+}
+
+func addItem(tx *sql.Tx, cartID int, item models.CheckoutItem) error {
+	if item.Quantity <= 0 {
+		return fmt.Errorf("invalid quantity %d for product id %d", item.Quantity, item.ProductID)
+	}
+
+	if item.VariantID != 0 {
+		if err := resolveVariantProduct(tx, &item); err != nil {
+			return err
+		}
+	} else {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)", item.ProductID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("product id %d not found", item.ProductID)
+		}
+	}
+
+	// variant_id is 0 rather than NULL for plain products and modifier_ids
+	// is the sorted id list, so the unique key (cart_id, product_id,
+	// variant_id, modifier_ids) merges repeated adds of the same line.
+	query := `INSERT INTO cart_items (cart_id, product_id, variant_id, modifier_ids, quantity) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (cart_id, product_id, variant_id, modifier_ids) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`
+	_, err := tx.Exec(query, cartID, item.ProductID, item.VariantID, modifierKey(item.ModifierIDs), item.Quantity)
+	return err
+}
+
+// resolveVariantProduct sets item's product to the variant's parent, so a
+// line is always keyed by the product its variant belongs to.
+func resolveVariantProduct(q querier, item *models.CheckoutItem) error {
+	if item.VariantID == 0 {
+		return nil
+	}
+	var productID int
+	err := q.QueryRow("SELECT product_id FROM product_variants WHERE id = $1", item.VariantID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("variant id %d not found", item.VariantID)
+	}
+	if err != nil {
+		return err
+	}
+	if item.ProductID != 0 && item.ProductID != productID {
+		return fmt.Errorf("variant id %d does not belong to product id %d", item.VariantID, item.ProductID)
+	}
+	item.ProductID = productID
+	return nil
+}
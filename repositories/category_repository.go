@@ -51,40 +51,86 @@ func (r *CategoryRepository) GetByID(id int) (*models.Category, error) {
 	return &category, nil
 }
 
-func (r *CategoryRepository) Create(category *models.Category) error {
+func (r *CategoryRepository) Create(category *models.Category, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := "INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id"
-	err := r.db.QueryRow(query, category.Name, category.Description).Scan(&category.ID)
-	return err
+	err = tx.QueryRow(query, category.Name, category.Description).Scan(&category.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "category", category.ID, nil, category); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *CategoryRepository) Update(category *models.Category) error {
-	query := "UPDATE categories SET name = $1, description = $2, updated_at = NOW() WHERE id = $3"
-	result, err := r.db.Exec(query, category.Name, category.Description, category.ID)
+func (r *CategoryRepository) Update(category *models.Category, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCategory(tx, category.ID)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	query := "UPDATE categories SET name = $1, description = $2, updated_at = NOW() WHERE id = $3"
+	_, err = tx.Exec(query, category.Name, category.Description, category.ID)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return errors.New("category not found")
+
+	if err := writeAudit(tx, actor, AuditUpdate, "category", category.ID, before, category); err != nil {
+		return err
 	}
-	return nil
+
+	return tx.Commit()
 }
 
-func (r *CategoryRepository) Delete(id int) error {
-	query := "DELETE FROM categories WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+func (r *CategoryRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	before, err := lockCategory(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM categories WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return errors.New("category not found")
+
+	if err := writeAudit(tx, actor, AuditDelete, "category", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lockCategory(tx *sql.Tx, id int) (*models.Category, error) {
+	query := "SELECT id, name, COALESCE(description, '') FROM categories WHERE id = $1 FOR UPDATE"
+
+	var category models.Category
+	err := tx.QueryRow(query, id).Scan(&category.ID, &category.Name, &category.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("category not found")
+		}
+		return nil, err
 	}
-	return nil
+
+	return &category, nil
 }
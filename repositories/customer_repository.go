@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type CustomerRepository struct {
+	db *sql.DB
+}
+
+func NewCustomerRepository(db *sql.DB) *CustomerRepository {
+	return &CustomerRepository{db: db}
+}
+
+func (r *CustomerRepository) GetAll(name, phone string) ([]models.Customer, error) {
+	query := "SELECT id, name, phone, email, notes, created_at FROM customers WHERE 1 = 1"
+	args := []interface{}{}
+	if name != "" {
+		args = append(args, "%"+name+"%")
+		query += fmt.Sprintf(" AND name ILIKE $%d", len(args))
+	}
+	if phone != "" {
+		args = append(args, "%"+phone+"%")
+		query += fmt.Sprintf(" AND phone LIKE $%d", len(args))
+	}
+	query += " ORDER BY name"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []models.Customer
+	for rows.Next() {
+		var customer models.Customer
+		err := rows.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &customer.Notes, &customer.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+
+	return customers, nil
+}
+
+func (r *CustomerRepository) GetByID(id int) (*models.Customer, error) {
+	query := "SELECT id, name, phone, email, notes, created_at FROM customers WHERE id = $1"
+
+	var customer models.Customer
+	err := r.db.QueryRow(query, id).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &customer.Notes, &customer.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("customer not found")
+		}
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+func (r *CustomerRepository) Create(customer *models.Customer, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO customers (name, phone, email, notes) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+	err = tx.QueryRow(query, customer.Name, customer.Phone, customer.Email, customer.Notes).Scan(&customer.ID, &customer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "customer", customer.ID, nil, customer); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *CustomerRepository) Update(customer *models.Customer, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCustomer(tx, customer.ID)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE customers SET name = $1, phone = $2, email = $3, notes = $4, updated_at = NOW() WHERE id = $5"
+	_, err = tx.Exec(query, customer.Name, customer.Phone, customer.Email, customer.Notes, customer.ID)
+	if err != nil {
+		return err
+	}
+	customer.CreatedAt = before.CreatedAt
+
+	if err := writeAudit(tx, actor, AuditUpdate, "customer", customer.ID, before, customer); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *CustomerRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCustomer(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// past sales stay, they just become anonymous. The points go with the
+	// account, while gift cards keep their balance as bearer cards.
+	_, err = tx.Exec("UPDATE transactions SET customer_id = NULL WHERE customer_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM loyalty_ledger WHERE customer_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE gift_cards SET customer_id = NULL WHERE customer_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM customers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "customer", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetHistory returns lifetime spend and visit counts worked out from the
+// customer's transactions.
+func (r *CustomerRepository) GetHistory(id int) (*models.CustomerHistory, error) {
+	customer, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	history := models.CustomerHistory{Customer: *customer}
+	query := "SELECT COALESCE(SUM(total_amount), 0), COUNT(id), MIN(created_at), MAX(created_at) FROM transactions WHERE customer_id = $1 AND refunded_at IS NULL"
+	err = r.db.QueryRow(query, id).Scan(&history.LifetimeSpend, &history.VisitCount, &history.FirstVisit, &history.LastVisit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+func lockCustomer(tx *sql.Tx, id int) (*models.Customer, error) {
+	query := "SELECT id, name, phone, email, notes, created_at FROM customers WHERE id = $1 FOR UPDATE"
+
+	var customer models.Customer
+	err := tx.QueryRow(query, id).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &customer.Notes, &customer.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("customer not found")
+		}
+		return nil, err
+	}
+
+	return &customer, nil
+}
@@ -0,0 +1,248 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"strings"
+	"time"
+)
+
+type GiftCardRepository struct {
+	db *sql.DB
+}
+
+func NewGiftCardRepository(db *sql.DB) *GiftCardRepository {
+	return &GiftCardRepository{db: db}
+}
+
+const giftCardSelect = `SELECT id, code, initial_balance, balance, status, COALESCE(customer_id, 0), expires_at, created_at FROM gift_cards`
+
+func scanGiftCard(row rowScanner, g *models.GiftCard) error {
+	return row.Scan(&g.ID, &g.Code, &g.InitialBalance, &g.Balance, &g.Status, &g.CustomerID, &g.ExpiresAt, &g.CreatedAt)
+}
+
+func (r *GiftCardRepository) GetAll(customerID int) ([]models.GiftCard, error) {
+	query := giftCardSelect
+	args := []interface{}{}
+	if customerID != 0 {
+		query += " WHERE customer_id = $1"
+		args = append(args, customerID)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := make([]models.GiftCard, 0)
+	for rows.Next() {
+		var card models.GiftCard
+		if err := scanGiftCard(rows, &card); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+func (r *GiftCardRepository) GetByCode(code string) (*models.GiftCard, error) {
+	var card models.GiftCard
+	err := scanGiftCard(r.db.QueryRow(giftCardSelect+" WHERE code = $1", normalizeGiftCardCode(code)), &card)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("gift card not found")
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT id, gift_card_id, transaction_id, type, amount, balance_after, created_at
+		FROM gift_card_ledger WHERE gift_card_id = $1 ORDER BY id DESC`, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	card.Ledger = make([]models.GiftCardLedgerEntry, 0)
+	for rows.Next() {
+		var e models.GiftCardLedgerEntry
+		if err := rows.Scan(&e.ID, &e.GiftCardID, &e.TransactionID, &e.Type, &e.Amount, &e.BalanceAfter, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		card.Ledger = append(card.Ledger, e)
+	}
+
+	return &card, rows.Err()
+}
+
+// Issue creates a card. A card sold for cash is booked as cash taken into
+// the issuer's open shift, so the drawer count at close expects it.
+func (r *GiftCardRepository) Issue(card *models.GiftCard, paidCash bool, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	card.Code = normalizeGiftCardCode(card.Code)
+	card.Balance = card.InitialBalance
+	card.Status = models.GiftCardStatusActive
+
+	query := `INSERT INTO gift_cards (code, initial_balance, balance, status, customer_id, expires_at)
+		VALUES ($1, $2, $2, $3, NULLIF($4, 0), $5) RETURNING id, created_at`
+	err = tx.QueryRow(query, card.Code, card.InitialBalance, card.Status, card.CustomerID, card.ExpiresAt).Scan(&card.ID, &card.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := postGiftCard(tx, card.ID, 0, models.GiftCardIssue, card.InitialBalance, card.Balance); err != nil {
+		return err
+	}
+	if err := writeAudit(tx, actor, AuditCreate, "gift_card", card.ID, nil, card); err != nil {
+		return err
+	}
+
+	if paidCash {
+		shiftID, err := openShiftFor(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+		m := models.CashMovement{ShiftID: shiftID, Type: models.CashMovementIn, Amount: card.InitialBalance, Reason: "gift card " + card.Code, CreatedBy: actor.UserID}
+		query := "INSERT INTO shift_cash_movements (shift_id, type, amount, reason, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+		err = tx.QueryRow(query, m.ShiftID, m.Type, m.Amount, m.Reason, m.CreatedBy).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if err := writeAudit(tx, actor, AuditCreate, "shift_cash_movement", m.ID, nil, m); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Void zeroes a card's balance so it can no longer be spent.
+func (r *GiftCardRepository) Void(code string, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockGiftCard(tx, code)
+	if err != nil {
+		return err
+	}
+	if before.Status == models.GiftCardStatusVoid {
+		return fmt.Errorf("gift card %s is already void", before.Code)
+	}
+
+	_, err = tx.Exec("UPDATE gift_cards SET status = $1, balance = 0 WHERE id = $2", models.GiftCardStatusVoid, before.ID)
+	if err != nil {
+		return err
+	}
+	if err := postGiftCard(tx, before.ID, 0, models.GiftCardVoid, -before.Balance, 0); err != nil {
+		return err
+	}
+
+	after := *before
+	after.Status = models.GiftCardStatusVoid
+	after.Balance = 0
+	if err := writeAudit(tx, actor, AuditDelete, "gift_card", before.ID, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// lockGiftCard reads a card FOR UPDATE, so concurrent checkouts spending
+// the same card queue up behind each other instead of double spending.
+func lockGiftCard(tx *sql.Tx, code string) (*models.GiftCard, error) {
+	var card models.GiftCard
+	err := scanGiftCard(tx.QueryRow(giftCardSelect+" WHERE code = $1 FOR UPDATE", normalizeGiftCardCode(code)), &card)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("gift card %s not found", code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// redeemGiftCard takes amount off the card for a checkout.
+func redeemGiftCard(tx *sql.Tx, code string, amount, transactionID int) error {
+	card, err := lockGiftCard(tx, code)
+	if err != nil {
+		return err
+	}
+	if card.Status != models.GiftCardStatusActive {
+		return fmt.Errorf("gift card %s is %s", card.Code, card.Status)
+	}
+	if card.ExpiresAt != nil && !time.Now().Before(*card.ExpiresAt) {
+		return fmt.Errorf("gift card %s expired on %s", card.Code, card.ExpiresAt.Format("2006-01-02"))
+	}
+	if card.Balance < amount {
+		return fmt.Errorf("insufficient gift card balance: need %d, balance %d", amount, card.Balance)
+	}
+
+	balance := card.Balance - amount
+	_, err = tx.Exec("UPDATE gift_cards SET balance = $1 WHERE id = $2", balance, card.ID)
+	if err != nil {
+		return err
+	}
+	return postGiftCard(tx, card.ID, transactionID, models.GiftCardRedeem, -amount, balance)
+}
+
+// reverseGiftCards puts gift card money spent on a transaction back on the
+// cards, for refunds. Void cards stay void and are skipped.
+func reverseGiftCards(tx *sql.Tx, transactionID int) error {
+	rows, err := tx.Query(`SELECT gift_card_id, -SUM(amount) FROM gift_card_ledger
+		WHERE transaction_id = $1 AND type = $2 GROUP BY gift_card_id`, transactionID, models.GiftCardRedeem)
+	if err != nil {
+		return err
+	}
+
+	amounts := map[int]int{}
+	for rows.Next() {
+		var id, amount int
+		if err := rows.Scan(&id, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		amounts[id] = amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, amount := range amounts {
+		var balance int
+		err := tx.QueryRow("UPDATE gift_cards SET balance = balance + $1 WHERE id = $2 AND status = $3 RETURNING balance",
+			amount, id, models.GiftCardStatusActive).Scan(&balance)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := postGiftCard(tx, id, transactionID, models.GiftCardRefund, amount, balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func postGiftCard(tx *sql.Tx, giftCardID, transactionID int, entryType string, amount, balanceAfter int) error {
+	_, err := tx.Exec("INSERT INTO gift_card_ledger (gift_card_id, transaction_id, type, amount, balance_after) VALUES ($1, NULLIF($2, 0), $3, $4, $5)",
+		giftCardID, transactionID, entryType, amount, balanceAfter)
+	return err
+}
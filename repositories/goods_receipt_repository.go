@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"time"
+)
+
+type GoodsReceiptRepository struct {
+	db     *sql.DB
+	ledger *StockLedger
+}
+
+func NewGoodsReceiptRepository(db *sql.DB, ledger *StockLedger) *GoodsReceiptRepository {
+	return &GoodsReceiptRepository{db: db, ledger: ledger}
+}
+
+const goodsReceiptSelect = `SELECT r.id, COALESCE(r.purchase_order_id, 0), COALESCE(r.supplier_id, 0), COALESCE(r.store_id, 0), r.reference, r.supplier, r.note, COALESCE(r.received_by, 0), r.received_at,
+	COALESCE((SELECT SUM(i.quantity * i.cost_price) FROM goods_receipt_items i WHERE i.receipt_id = r.id), 0)
+	FROM goods_receipts r`
+
+func scanGoodsReceipt(row rowScanner, g *models.GoodsReceipt) error {
+	return row.Scan(&g.ID, &g.PurchaseOrderID, &g.SupplierID, &g.StoreID, &g.Reference, &g.Supplier, &g.Note, &g.ReceivedBy, &g.ReceivedAt, &g.TotalCost)
+}
+
+func (r *GoodsReceiptRepository) GetAll(from, to time.Time) ([]models.GoodsReceipt, error) {
+	query := goodsReceiptSelect + " WHERE r.received_at >= $1 AND r.received_at < $2 ORDER BY r.received_at DESC"
+
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := make([]models.GoodsReceipt, 0)
+	for rows.Next() {
+		var receipt models.GoodsReceipt
+		if err := scanGoodsReceipt(rows, &receipt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, rows.Err()
+}
+
+func (r *GoodsReceiptRepository) GetByID(id int) (*models.GoodsReceipt, error) {
+	var receipt models.GoodsReceipt
+	err := scanGoodsReceipt(r.db.QueryRow(goodsReceiptSelect+" WHERE r.id = $1", id), &receipt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("goods receipt not found")
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT i.product_id, COALESCE(p.name, ''), COALESCE(i.variant_id, 0), i.quantity, i.cost_price,
+			COALESCE(i.lot_number, ''), COALESCE(TO_CHAR(i.expires_at, 'YYYY-MM-DD'), '')
+		FROM goods_receipt_items i
+		LEFT JOIN products p ON p.id = i.product_id
+		WHERE i.receipt_id = $1
+		ORDER BY i.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipt.Items = make([]models.GoodsReceiptItem, 0)
+	for rows.Next() {
+		var item models.GoodsReceiptItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.VariantID, &item.Quantity, &item.CostPrice, &item.LotNumber, &item.ExpiresAt); err != nil {
+			return nil, err
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	return &receipt, rows.Err()
+}
+
+// Create posts a goods receipt: the received quantities go into stock as
+// new cost layers and each product or variant takes the receipt's cost as
+// its cost price.
+func (r *GoodsReceiptRepository) Create(receipt *models.GoodsReceipt, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := postGoodsReceipt(tx, r.ledger, receipt, actor); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// postGoodsReceipt stores and books a goods receipt inside tx, into the
+// stock of the store the actor works in. A receipt from a known supplier
+// also refreshes the supplier's last cost for each item.
+func postGoodsReceipt(tx *sql.Tx, ledger *StockLedger, receipt *models.GoodsReceipt, actor models.Actor) error {
+	key, err := storeKey(tx, actor.StoreID)
+	if err != nil {
+		return err
+	}
+	receipt.StoreID = key
+
+	if receipt.SupplierID != 0 {
+		err := tx.QueryRow("SELECT name FROM suppliers WHERE id = $1", receipt.SupplierID).Scan(&receipt.Supplier)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("supplier id %d not found", receipt.SupplierID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO goods_receipts (purchase_order_id, supplier_id, store_id, reference, supplier, note, received_by)
+		VALUES (NULLIF($1, 0), NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, NULLIF($7, 0)) RETURNING id, received_at`
+	err = tx.QueryRow(query, receipt.PurchaseOrderID, receipt.SupplierID, receipt.StoreID, receipt.Reference, receipt.Supplier, receipt.Note, actor.UserID).
+		Scan(&receipt.ID, &receipt.ReceivedAt)
+	if err != nil {
+		return err
+	}
+	receipt.ReceivedBy = actor.UserID
+
+	receipt.TotalCost = 0
+	for i := range receipt.Items {
+		item := &receipt.Items[i]
+		if err := receiveStock(tx, ledger, receipt.ID, key, item); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO goods_receipt_items (receipt_id, product_id, variant_id, quantity, cost_price, lot_number, expires_at)
+			VALUES ($1, $2, NULLIF($3, 0), $4, $5, NULLIF($6, ''), NULLIF($7, '')::date)`,
+			receipt.ID, item.ProductID, item.VariantID, item.Quantity, item.CostPrice, item.LotNumber, item.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if receipt.SupplierID != 0 {
+			_, err = tx.Exec(`INSERT INTO supplier_products (supplier_id, product_id, variant_id, last_cost) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (supplier_id, product_id, variant_id) DO UPDATE SET last_cost = EXCLUDED.last_cost`,
+				receipt.SupplierID, item.ProductID, item.VariantID, item.CostPrice)
+			if err != nil {
+				return err
+			}
+		}
+		receipt.TotalCost += item.Quantity * item.CostPrice
+	}
+
+	return writeAudit(tx, actor, AuditCreate, "goods_receipt", receipt.ID, nil, receipt)
+}
+
+// receiveStock books one received line onto the product or variant, and
+// into its lot when the product is lot tracked. Composite products hold no
+// stock, so their components are received instead.
+func receiveStock(tx *sql.Tx, ledger *StockLedger, receiptID, storeKey int, item *models.GoodsReceiptItem) error {
+	unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: item.ProductID, VariantID: item.VariantID}, "", true, storeKey)
+	if err != nil {
+		return err
+	}
+	if len(unit.components) > 0 {
+		return fmt.Errorf("%s is made from components, receive the components instead", unit.name)
+	}
+	item.ProductID = unit.productID
+	item.ProductName = unit.label()
+
+	tracked, err := lotTracked(tx, item.ProductID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case tracked && item.LotNumber == "":
+		return fmt.Errorf("%s is lot tracked, lot_number is required", item.ProductName)
+	case !tracked && (item.LotNumber != "" || item.ExpiresAt != ""):
+		return fmt.Errorf("%s is not lot tracked", item.ProductName)
+	case tracked:
+		if err := putLot(tx, item.ProductID, item.VariantID, storeKey, item.LotNumber, item.ExpiresAt, item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	ref := stockReference{models.StockMovementReceipt, "goods_receipt", receiptID, storeKey}
+	if err := ledger.receive(tx, item.ProductID, item.VariantID, item.Quantity, item.CostPrice, ref); err != nil {
+		return err
+	}
+
+	table, id := stockTable(item.ProductID, item.VariantID)
+	_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET cost_price = $1 WHERE id = $2", table), item.CostPrice, id)
+	return err
+}
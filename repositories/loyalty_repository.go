@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"math"
+)
+
+var defaultLoyaltyRules = models.LoyaltyRules{
+	Enabled:        true,
+	RupiahPerPoint: 10000,
+	PointValue:     100,
+}
+
+type LoyaltyRepository struct {
+	db *sql.DB
+}
+
+func NewLoyaltyRepository(db *sql.DB) *LoyaltyRepository {
+	return &LoyaltyRepository{db: db}
+}
+
+func (r *LoyaltyRepository) GetRules() (*models.LoyaltyRules, error) {
+	return loadLoyaltyRules(r.db)
+}
+
+func (r *LoyaltyRepository) UpdateRules(rules *models.LoyaltyRules, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := loadLoyaltyRules(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO loyalty_rules (id, enabled, rupiah_per_point, point_value) VALUES (1, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET enabled = EXCLUDED.enabled, rupiah_per_point = EXCLUDED.rupiah_per_point,
+		point_value = EXCLUDED.point_value, updated_at = NOW()`
+	_, err = tx.Exec(query, rules.Enabled, rules.RupiahPerPoint, rules.PointValue)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM loyalty_category_multipliers")
+	if err != nil {
+		return err
+	}
+	for _, m := range rules.CategoryMultipliers {
+		_, err = tx.Exec("INSERT INTO loyalty_category_multipliers (category_id, multiplier) VALUES ($1, $2)", m.CategoryID, m.Multiplier)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "loyalty_rules", 1, before, rules); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *LoyaltyRepository) GetAccount(customerID int) (*models.LoyaltyAccount, error) {
+	rules, err := loadLoyaltyRules(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	account := models.LoyaltyAccount{CustomerID: customerID, PointValue: rules.PointValue}
+	err = r.db.QueryRow("SELECT loyalty_points FROM customers WHERE id = $1", customerID).Scan(&account.Balance)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("customer id %d not found", customerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT id, customer_id, transaction_id, type, points, balance_after, created_at
+		FROM loyalty_ledger WHERE customer_id = $1 ORDER BY id DESC LIMIT 100`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	account.Ledger = make([]models.LoyaltyLedgerEntry, 0)
+	for rows.Next() {
+		var e models.LoyaltyLedgerEntry
+		err := rows.Scan(&e.ID, &e.CustomerID, &e.TransactionID, &e.Type, &e.Points, &e.BalanceAfter, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		account.Ledger = append(account.Ledger, e)
+	}
+
+	return &account, rows.Err()
+}
+
+func loadLoyaltyRules(q querier) (*models.LoyaltyRules, error) {
+	rules := defaultLoyaltyRules
+	err := q.QueryRow("SELECT enabled, rupiah_per_point, point_value FROM loyalty_rules WHERE id = 1").
+		Scan(&rules.Enabled, &rules.RupiahPerPoint, &rules.PointValue)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := q.Query("SELECT category_id, multiplier FROM loyalty_category_multipliers ORDER BY category_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules.CategoryMultipliers = make([]models.CategoryMultiplier, 0)
+	for rows.Next() {
+		var m models.CategoryMultiplier
+		if err := rows.Scan(&m.CategoryID, &m.Multiplier); err != nil {
+			return nil, err
+		}
+		rules.CategoryMultipliers = append(rules.CategoryMultipliers, m)
+	}
+
+	return &rules, rows.Err()
+}
+
+// redeemPoints spends the customer's points to pay amount rupiah. The
+// customer row stays locked until tx ends, so two checkouts cannot spend
+// the same points.
+func redeemPoints(tx *sql.Tx, rules *models.LoyaltyRules, customerID, amount int) (int, error) {
+	if !rules.Enabled {
+		return 0, fmt.Errorf("loyalty points are not enabled")
+	}
+	if customerID == 0 {
+		return 0, fmt.Errorf("paying with points needs a customer")
+	}
+	if amount%rules.PointValue != 0 {
+		return 0, fmt.Errorf("points payment must be a multiple of %d", rules.PointValue)
+	}
+	points := amount / rules.PointValue
+
+	var balance int
+	err := tx.QueryRow("SELECT loyalty_points FROM customers WHERE id = $1 FOR UPDATE", customerID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	if balance < points {
+		return 0, fmt.Errorf("insufficient points: need %d, balance %d", points, balance)
+	}
+
+	return points, nil
+}
+
+// earnedPoints works out the points a sale earns: every detail's subtotal
+// weighted by its category multiplier, minus the share paid with points.
+func earnedPoints(tx *sql.Tx, rules *models.LoyaltyRules, details []models.TransactionDetail, totalAmount, pointsPaid int) (int, error) {
+	if !rules.Enabled || rules.RupiahPerPoint <= 0 || totalAmount <= 0 {
+		return 0, nil
+	}
+
+	multipliers := make(map[int]float64, len(rules.CategoryMultipliers))
+	for _, m := range rules.CategoryMultipliers {
+		multipliers[m.CategoryID] = m.Multiplier
+	}
+
+	weighted := 0.0
+	for _, d := range details {
+		var categoryID sql.NullInt64
+		err := tx.QueryRow("SELECT category_id FROM products WHERE id = $1", d.ProductID).Scan(&categoryID)
+		if err != nil {
+			return 0, err
+		}
+		multiplier, ok := multipliers[int(categoryID.Int64)]
+		if !ok {
+			multiplier = 1
+		}
+		weighted += float64(d.Subtotal) * multiplier
+	}
+
+	eligible := weighted * float64(totalAmount-pointsPaid) / float64(totalAmount)
+	return int(math.Floor(eligible / float64(rules.RupiahPerPoint))), nil
+}
+
+// postPoints moves a customer's balance by points (negative to spend) and
+// writes the ledger entry.
+func postPoints(tx *sql.Tx, customerID, transactionID int, entryType string, points int) error {
+	if points == 0 {
+		return nil
+	}
+
+	var balance int
+	err := tx.QueryRow("UPDATE customers SET loyalty_points = loyalty_points + $1 WHERE id = $2 RETURNING loyalty_points", points, customerID).Scan(&balance)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO loyalty_ledger (customer_id, transaction_id, type, points, balance_after) VALUES ($1, $2, $3, $4, $5)",
+		customerID, transactionID, entryType, points, balance)
+	return err
+}
+
+// reversePoints undoes every point movement of a transaction, for refunds.
+// Earned points are clawed back even if that takes the balance negative.
+// Customers deleted since the sale are skipped.
+func reversePoints(tx *sql.Tx, transactionID int) error {
+	rows, err := tx.Query(`SELECT l.customer_id, l.type, SUM(l.points) FROM loyalty_ledger l JOIN customers c ON c.id = l.customer_id
+		WHERE l.transaction_id = $1 AND l.type IN ($2, $3) GROUP BY l.customer_id, l.type`,
+		transactionID, models.LoyaltyEarn, models.LoyaltyRedeem)
+	if err != nil {
+		return err
+	}
+
+	type movement struct {
+		customerID int
+		entryType  string
+		points     int
+	}
+	var movements []movement
+	for rows.Next() {
+		var m movement
+		if err := rows.Scan(&m.customerID, &m.entryType, &m.points); err != nil {
+			rows.Close()
+			return err
+		}
+		movements = append(movements, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range movements {
+		entryType := models.LoyaltyClawback
+		if m.entryType == models.LoyaltyRedeem {
+			entryType = models.LoyaltyRefund
+		}
+		if err := postPoints(tx, m.customerID, transactionID, entryType, -m.points); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ModifierRepository struct {
+	db *sql.DB
+}
+
+func NewModifierRepository(db *sql.DB) *ModifierRepository {
+	return &ModifierRepository{db: db}
+}
+
+func (r *ModifierRepository) GetAll() ([]models.ModifierGroup, error) {
+	return getModifierGroups(r.db, "")
+}
+
+func (r *ModifierRepository) GetByID(id int) (*models.ModifierGroup, error) {
+	groups, err := getModifierGroups(r.db, "WHERE g.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("modifier group not found")
+	}
+	return &groups[0], nil
+}
+
+func (r *ModifierRepository) Create(group *models.ModifierGroup, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO modifier_groups (name, required, min_select, max_select) VALUES ($1, $2, $3, $4) RETURNING id"
+	err = tx.QueryRow(query, group.Name, group.Required, group.MinSelect, group.MaxSelect).Scan(&group.ID)
+	if err != nil {
+		return err
+	}
+	if err := saveModifiers(tx, group); err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "modifier_group", group.ID, nil, group); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update replaces the group's settings, options and product links.
+// Options keep their id when it is sent back, so sales history still
+// points at them.
+func (r *ModifierRepository) Update(group *models.ModifierGroup, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockModifierGroup(tx, group.ID)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE modifier_groups SET name = $1, required = $2, min_select = $3, max_select = $4 WHERE id = $5"
+	_, err = tx.Exec(query, group.Name, group.Required, group.MinSelect, group.MaxSelect, group.ID)
+	if err != nil {
+		return err
+	}
+	if err := saveModifiers(tx, group); err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "modifier_group", group.ID, before, group); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ModifierRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockModifierGroup(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM modifier_groups WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "modifier_group", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Popularity ranks modifiers by how many units were sold with them
+// between from and to, leaving out refunded sales.
+func (r *ModifierRepository) Popularity(from, to time.Time) ([]models.ModifierPopularity, error) {
+	query := `SELECT COALESCE(dm.modifier_id, 0), dm.name, COALESCE(g.name, ''), SUM(d.quantity), SUM(dm.price_delta * d.quantity)
+		FROM transaction_detail_modifiers dm
+		JOIN transaction_details d ON d.id = dm.detail_id
+		JOIN transactions t ON t.id = d.transaction_id
+		LEFT JOIN modifiers m ON m.id = dm.modifier_id
+		LEFT JOIN modifier_groups g ON g.id = m.group_id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.refunded_at IS NULL
+		GROUP BY dm.modifier_id, dm.name, g.name
+		ORDER BY 4 DESC, 5 DESC`
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	popularity := make([]models.ModifierPopularity, 0)
+	for rows.Next() {
+		var p models.ModifierPopularity
+		if err := rows.Scan(&p.ModifierID, &p.Name, &p.GroupName, &p.Quantity, &p.Revenue); err != nil {
+			return nil, err
+		}
+		popularity = append(popularity, p)
+	}
+
+	return popularity, rows.Err()
+}
+
+// getModifierGroups loads groups with their options and product links.
+// clause filters the modifier_groups g rows.
+func getModifierGroups(q querier, clause string, args ...interface{}) ([]models.ModifierGroup, error) {
+	rows, err := q.Query("SELECT g.id, g.name, g.required, g.min_select, g.max_select FROM modifier_groups g "+clause+" ORDER BY g.name, g.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]models.ModifierGroup, 0)
+	index := make(map[int]int)
+	for rows.Next() {
+		var g models.ModifierGroup
+		if err := rows.Scan(&g.ID, &g.Name, &g.Required, &g.MinSelect, &g.MaxSelect); err != nil {
+			return nil, err
+		}
+		g.Modifiers = make([]models.Modifier, 0)
+		g.ProductIDs = make([]int, 0)
+		index[g.ID] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	modifierRows, err := q.Query("SELECT m.id, m.group_id, m.name, m.price_delta FROM modifiers m JOIN modifier_groups g ON g.id = m.group_id "+clause+" ORDER BY m.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer modifierRows.Close()
+	for modifierRows.Next() {
+		var m models.Modifier
+		if err := modifierRows.Scan(&m.ID, &m.GroupID, &m.Name, &m.PriceDelta); err != nil {
+			return nil, err
+		}
+		g := &groups[index[m.GroupID]]
+		g.Modifiers = append(g.Modifiers, m)
+	}
+	if err := modifierRows.Err(); err != nil {
+		return nil, err
+	}
+
+	linkRows, err := q.Query("SELECT l.group_id, l.product_id FROM product_modifier_groups l JOIN modifier_groups g ON g.id = l.group_id "+clause+" ORDER BY l.product_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer linkRows.Close()
+	for linkRows.Next() {
+		var groupID, productID int
+		if err := linkRows.Scan(&groupID, &productID); err != nil {
+			return nil, err
+		}
+		g := &groups[index[groupID]]
+		g.ProductIDs = append(g.ProductIDs, productID)
+	}
+
+	return groups, linkRows.Err()
+}
+
+// productModifierGroups returns the groups linked to each product.
+func productModifierGroups(q querier) (map[int][]models.ModifierGroup, error) {
+	groups, err := getModifierGroups(q, "")
+	if err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[int][]models.ModifierGroup)
+	for _, g := range groups {
+		for _, productID := range g.ProductIDs {
+			byProduct[productID] = append(byProduct[productID], g)
+		}
+	}
+	return byProduct, nil
+}
+
+// saveModifiers brings the group's options and product links in line with
+// group, inserting new options and dropping the ones no longer sent.
+func saveModifiers(tx *sql.Tx, group *models.ModifierGroup) error {
+	keep := make([]string, 0, len(group.Modifiers))
+	for i := range group.Modifiers {
+		m := &group.Modifiers[i]
+		m.GroupID = group.ID
+		if m.ID != 0 {
+			result, err := tx.Exec("UPDATE modifiers SET name = $1, price_delta = $2 WHERE id = $3 AND group_id = $4", m.Name, m.PriceDelta, m.ID, group.ID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return fmt.Errorf("modifier id %d is not in group %d", m.ID, group.ID)
+			}
+		} else {
+			err := tx.QueryRow("INSERT INTO modifiers (group_id, name, price_delta) VALUES ($1, $2, $3) RETURNING id", group.ID, m.Name, m.PriceDelta).Scan(&m.ID)
+			if err != nil {
+				return err
+			}
+		}
+		keep = append(keep, strconv.Itoa(m.ID))
+	}
+
+	query := "DELETE FROM modifiers WHERE group_id = $1"
+	if len(keep) > 0 {
+		query += " AND id NOT IN (" + strings.Join(keep, ", ") + ")"
+	}
+	if _, err := tx.Exec(query, group.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM product_modifier_groups WHERE group_id = $1", group.ID); err != nil {
+		return err
+	}
+	for _, productID := range group.ProductIDs {
+		_, err := tx.Exec("INSERT INTO product_modifier_groups (product_id, group_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", productID, group.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if group.ProductIDs == nil {
+		group.ProductIDs = make([]int, 0)
+	}
+	return nil
+}
+
+func lockModifierGroup(tx *sql.Tx, id int) (*models.ModifierGroup, error) {
+	var locked int
+	err := tx.QueryRow("SELECT id FROM modifier_groups WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("modifier group not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := getModifierGroups(tx, "WHERE g.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+// priceModifiers checks the selected modifiers against the product's groups
+// and returns them as sold, with the total price they add to one unit.
+func priceModifiers(tx *sql.Tx, productID int, modifierIDs []int) ([]models.DetailModifier, int, error) {
+	groups, err := getModifierGroups(tx, "JOIN product_modifier_groups pg ON pg.group_id = g.id AND pg.product_id = $1", productID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	selected := make(map[int]bool, len(modifierIDs))
+	for _, id := range modifierIDs {
+		if selected[id] {
+			return nil, 0, fmt.Errorf("modifier id %d selected twice", id)
+		}
+		selected[id] = true
+	}
+
+	var sold []models.DetailModifier
+	delta := 0
+	for _, g := range groups {
+		count := 0
+		for _, m := range g.Modifiers {
+			if !selected[m.ID] {
+				continue
+			}
+			delete(selected, m.ID)
+			count++
+			delta += m.PriceDelta
+			sold = append(sold, models.DetailModifier{ModifierID: m.ID, Name: m.Name, PriceDelta: m.PriceDelta})
+		}
+
+		min := g.MinSelect
+		if g.Required && min < 1 {
+			min = 1
+		}
+		if count < min {
+			return nil, 0, fmt.Errorf("%s needs at least %d selection(s)", g.Name, min)
+		}
+		if g.MaxSelect > 0 && count > g.MaxSelect {
+			return nil, 0, fmt.Errorf("%s allows at most %d selection(s)", g.Name, g.MaxSelect)
+		}
+	}
+
+	if len(selected) > 0 {
+		ids := make([]int, 0, len(selected))
+		for id := range selected {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		return nil, 0, fmt.Errorf("modifier id %d is not available for product id %d", ids[0], productID)
+	}
+
+	return sold, delta, nil
+}
+
+// modifierKey is the canonical form of a modifier selection, used to tell
+// cart lines of the same product apart.
+func modifierKey(modifierIDs []int) string {
+	ids := append([]int(nil), modifierIDs...)
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
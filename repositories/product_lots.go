@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"time"
+)
+
+// lotTracked reports whether a product keeps its stock, and its variants',
+// in lots.
+func lotTracked(q querier, productID int) (bool, error) {
+	var tracked bool
+	err := q.QueryRow("SELECT lot_tracked FROM products WHERE id = $1", productID).Scan(&tracked)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("product id %d not found", productID)
+	}
+	return tracked, err
+}
+
+// putLot adds qty to a lot at the store keyed by store, creating it the
+// first time the lot number is received there. A lot keeps the expiry date
+// it was first received with.
+func putLot(tx *sql.Tx, productID, variantID, store int, lotNumber, expiresAt string, qty int) error {
+	_, err := tx.Exec(`INSERT INTO product_lots (product_id, variant_id, store_id, lot_number, expires_at, quantity)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, $6)
+		ON CONFLICT (product_id, variant_id, store_id, lot_number) DO UPDATE SET quantity = product_lots.quantity + EXCLUDED.quantity`,
+		productID, variantID, store, lotNumber, expiresAt, qty)
+	return err
+}
+
+// takeLots removes qty from a product's lots at the store keyed by store,
+// first expiring first out, and returns what it took from which lot. A
+// sale passes its business date: only lots unexpired on that date can be
+// used and running short is an error. Write-offs pass the zero time; they
+// take expired lots first and stop at what the lots hold.
+func takeLots(tx *sql.Tx, productID, variantID, store, qty int, saleDate time.Time) ([]models.DetailLot, error) {
+	sale := !saleDate.IsZero()
+	query := `SELECT id, lot_number, COALESCE(TO_CHAR(expires_at, 'YYYY-MM-DD'), ''), quantity FROM product_lots
+		WHERE product_id = $1 AND variant_id = $2 AND store_id = $3 AND quantity > 0`
+	args := []interface{}{productID, variantID, store}
+	if sale {
+		args = append(args, saleDate.Format("2006-01-02"))
+		query += " AND (expires_at IS NULL OR expires_at >= $4::date)"
+	}
+	query += " ORDER BY expires_at NULLS LAST, id FOR UPDATE"
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var lots []models.DetailLot
+	for rows.Next() {
+		l := models.DetailLot{ProductID: productID}
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiresAt, &l.Quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	taken := make([]models.DetailLot, 0, 1)
+	left := qty
+	for _, l := range lots {
+		if left == 0 {
+			break
+		}
+		l.Quantity = min(left, l.Quantity)
+		_, err := tx.Exec("UPDATE product_lots SET quantity = quantity - $1 WHERE id = $2", l.Quantity, l.LotID)
+		if err != nil {
+			return nil, err
+		}
+		taken = append(taken, l)
+		left -= l.Quantity
+	}
+	if left > 0 && sale {
+		return nil, fmt.Errorf("insufficient unexpired stock for product id %d: short by %d", productID, left)
+	}
+
+	return taken, nil
+}
+
+// seedLots starts lot tracking for a product: the stock it and its
+// variants hold at each store goes into a lot without a number there.
+func seedLots(tx *sql.Tx, productID int) error {
+	_, err := tx.Exec(`INSERT INTO product_lots (product_id, variant_id, store_id, lot_number, quantity)
+		SELECT product_id, variant_id, store_id, '', stock FROM (
+			SELECT id AS product_id, 0 AS variant_id, 0 AS store_id, `+storeStockExpr("stock", "id", "0", "0")+` AS stock
+				FROM products WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1)
+			UNION ALL
+			SELECT product_id, id, 0, `+storeStockExpr("stock", "product_id", "id", "0")+` FROM product_variants WHERE product_id = $1
+			UNION ALL
+			SELECT product_id, variant_id, store_id, stock FROM store_stock WHERE product_id = $1
+		) s WHERE stock > 0
+		ON CONFLICT (product_id, variant_id, store_id, lot_number) DO UPDATE SET quantity = product_lots.quantity + EXCLUDED.quantity`, productID)
+	return err
+}
+
+// getLots lists lots with stock left, soonest expiry first, with the store
+// they are at and the days they have left as of the business date today.
+// Each lot is valued at its product's or variant's cost price. where reads
+// today as $1 and args from $2.
+func getLots(q querier, today time.Time, where string, args ...interface{}) ([]models.ProductLot, error) {
+	rows, err := q.Query(`SELECT l.id, l.product_id, l.variant_id, `+storeIDExpr("NULLIF(l.store_id, 0)")+`, p.name, COALESCE(v.name, ''), l.lot_number,
+			COALESCE(TO_CHAR(l.expires_at, 'YYYY-MM-DD'), ''), l.expires_at - $1::date, l.quantity, l.received_at,
+			l.quantity * COALESCE(v.cost_price, p.cost_price)
+		FROM product_lots l
+		JOIN products p ON p.id = l.product_id
+		LEFT JOIN product_variants v ON v.id = l.variant_id
+		WHERE l.quantity > 0 AND `+where+`
+		ORDER BY l.expires_at NULLS LAST, p.name, l.id`, append([]interface{}{today.Format("2006-01-02")}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := make([]models.ProductLot, 0)
+	for rows.Next() {
+		var l models.ProductLot
+		var variant string
+		var daysLeft sql.NullInt64
+		err := rows.Scan(&l.ID, &l.ProductID, &l.VariantID, &l.StoreID, &l.Name, &variant, &l.LotNumber, &l.ExpiresAt, &daysLeft, &l.Quantity, &l.ReceivedAt, &l.Value)
+		if err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			l.Name += " (" + variant + ")"
+		}
+		if daysLeft.Valid {
+			days := int(daysLeft.Int64)
+			l.DaysLeft = &days
+		}
+		lots = append(lots, l)
+	}
+
+	return lots, rows.Err()
+}
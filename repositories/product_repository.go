@@ -2,23 +2,40 @@ package repositories
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"kasir-api/models"
+	"time"
 )
 
 type ProductRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	ledger *StockLedger
+	day    models.BusinessDay
 }
 
-func NewProductRepository(db *sql.DB) *ProductRepository {
-	return &ProductRepository{db: db}
+func NewProductRepository(db *sql.DB, ledger *StockLedger, day models.BusinessDay) *ProductRepository {
+	return &ProductRepository{db: db, ledger: ledger, day: day}
 }
 
-func (r *ProductRepository) GetAll(name string) ([]models.Product, error) {
-	query := "SELECT id, name, price, stock, category_id FROM products"
-	args := []interface{}{}
+// productSelect reads products with the price and stock of the store keyed
+// by $1.
+var productSelect = "SELECT p.id, p.name, " + storePriceExpr("p.price", "p.id", "0", "$1") + ", p.cost_price, " +
+	storeStockExpr("p.stock", "p.id", "0", "$1") + ", " + storeStockExpr("p.stock", "p.id", "0", "$1") + " - " + reservedExpr("p.id", "''", "$1") +
+	", p.category_id, p.lot_tracked FROM products p"
+
+// GetAll lists products with their price and stock at a store.
+func (r *ProductRepository) GetAll(name string, storeID int) ([]models.Product, error) {
+	key, err := storeKey(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := productSelect
+	args := []interface{}{key}
 	if name != "" {
-		query += " WHERE name ILIKE $1"
+		query += " WHERE p.name ILIKE $2"
 		args = append(args, "%"+name+"%")
 	}
 	rows, err := r.db.Query(query, args...)
@@ -30,22 +47,69 @@ func (r *ProductRepository) GetAll(name string) ([]models.Product, error) {
 	var products []models.Product
 	for rows.Next() {
 		var product models.Product
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoryID)
+		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.CostPrice, &product.Stock, &product.AvailableStock, &product.CategoryID, &product.LotTracked)
 		if err != nil {
 			return nil, err
 		}
 		products = append(products, product)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	variantQuery := "JOIN products p ON p.id = v.product_id"
+	if name != "" {
+		variantQuery += " WHERE p.name ILIKE $2"
+	}
+	variants, err := getVariants(r.db, variantQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	modifierGroups, err := productModifierGroups(r.db)
+	if err != nil {
+		return nil, err
+	}
+	components, err := getComponents(r.db, "", key)
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		attachVariants(&products[i], variants[products[i].ID])
+		attachComponents(&products[i], components[products[i].ID])
+		products[i].ModifierGroups = modifierGroups[products[i].ID]
+	}
 
 	return products, nil
 }
 
-func (r *ProductRepository) GetByID(id int) (*models.Product, error) {
-	query := "SELECT id, name, price, stock, category_id FROM products WHERE id = $1"
-	row := r.db.QueryRow(query, id)
+// GetByID reads a product with its price and stock at a store.
+func (r *ProductRepository) GetByID(id, storeID int) (*models.Product, error) {
+	key, err := storeKey(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	row := r.db.QueryRow(productSelect+" WHERE p.id = $2", key, id)
 
 	var product models.Product
-	err := row.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoryID)
+	err = row.Scan(&product.ID, &product.Name, &product.Price, &product.CostPrice, &product.Stock, &product.AvailableStock, &product.CategoryID, &product.LotTracked)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := getVariants(r.db, "WHERE v.product_id = $2", key, id)
+	if err != nil {
+		return nil, err
+	}
+	attachVariants(&product, variants[id])
+
+	components, err := getComponents(r.db, "WHERE pc.product_id = $2", key, id)
+	if err != nil {
+		return nil, err
+	}
+	attachComponents(&product, components[id])
+
+	product.ModifierGroups, err = getModifierGroups(r.db, "JOIN product_modifier_groups pg ON pg.group_id = g.id AND pg.product_id = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -53,40 +117,453 @@ func (r *ProductRepository) GetByID(id int) (*models.Product, error) {
 	return &product, nil
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
-	query := "INSERT INTO products (name, price, stock, category_id) VALUES ($1, $2, $3, $4) RETURNING id"
-	err := r.db.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID).Scan(&product.ID)
-	return err
+func (r *ProductRepository) Create(product *models.Product, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Opening stock is booked through the ledger at the store the actor
+	// works in, the same as a stock change in Update.
+	key, err := storeKey(tx, actor.StoreID)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO products (name, price, cost_price, stock, category_id, lot_tracked) VALUES ($1, $2, $3, 0, $4, $5) RETURNING id"
+	err = tx.QueryRow(query, product.Name, product.Price, product.CostPrice, product.CategoryID, product.LotTracked).Scan(&product.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range product.Variants {
+		product.Variants[i].ProductID = product.ID
+		if err := r.insertVariant(tx, &product.Variants[i], key); err != nil {
+			return err
+		}
+	}
+	if len(product.Variants) == 0 {
+		opening := stockReference{models.StockMovementAdjustment, "product", product.ID, key}
+		if _, err := r.ledger.adjust(tx, product.ID, 0, product.Stock, opening); err != nil {
+			return err
+		}
+	}
+	product.AvailableStock = product.Stock
+	attachVariants(product, product.Variants)
+
+	if err := writeAudit(tx, actor, AuditCreate, "product", product.ID, nil, product); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *ProductRepository) Update(product *models.Product) error {
-	query := "UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4, updated_at = NOW() WHERE id = $5"
-	result, err := r.db.Exec(query, product.Name, product.Price, product.Stock, product.CategoryID, product.ID)
+// Update changes a product. Its stock is set at the store the actor works
+// in.
+func (r *ProductRepository) Update(product *models.Product, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockProduct(tx, product.ID)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	query := "UPDATE products SET name = $1, price = $2, cost_price = $3, category_id = $4, lot_tracked = $5, updated_at = NOW() WHERE id = $6"
+	_, err = tx.Exec(query, product.Name, product.Price, product.CostPrice, product.CategoryID, product.LotTracked, product.ID)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return errors.New("product not found")
+
+	// Turning lot tracking on puts the current stock into an unnumbered
+	// lot; turning it off empties the lots.
+	switch {
+	case product.LotTracked && !before.LotTracked:
+		err = seedLots(tx, product.ID)
+	case !product.LotTracked && before.LotTracked:
+		_, err = tx.Exec("UPDATE product_lots SET quantity = 0 WHERE product_id = $1", product.ID)
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+
+	// Products with variants or components keep their stock elsewhere.
+	var holdsStock bool
+	err = tx.QueryRow(`SELECT NOT EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1)
+		AND NOT EXISTS (SELECT 1 FROM product_components WHERE product_id = $1)`, product.ID).Scan(&holdsStock)
+	if err != nil {
+		return err
+	}
+	if holdsStock {
+		key, err := storeKey(tx, actor.StoreID)
+		if err != nil {
+			return err
+		}
+		stock, err := storeStock(tx, product.ID, 0, key)
+		if err != nil {
+			return err
+		}
+		adjustment := stockReference{models.StockMovementAdjustment, "product", product.ID, key}
+		if _, err := r.ledger.adjust(tx, product.ID, 0, product.Stock-stock, adjustment); err != nil {
+			return err
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "product", product.ID, before, product); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ProductRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockProduct(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "product", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockProduct reads a product row for update, as the before snapshot of a
+// change.
+func lockProduct(tx *sql.Tx, id int) (*models.Product, error) {
+	query := "SELECT id, name, price, cost_price, stock, category_id, lot_tracked FROM products WHERE id = $1 FOR UPDATE"
+
+	var product models.Product
+	err := tx.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Price, &product.CostPrice, &product.Stock, &product.CategoryID, &product.LotTracked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("product not found")
+		}
+		return nil, err
+	}
+	product.AvailableStock = product.Stock
+
+	return &product, nil
+}
+
+// GetLots lists the lots of a product and its variants that still hold
+// stock at a store.
+func (r *ProductRepository) GetLots(productID, storeID int) ([]models.ProductLot, error) {
+	key, err := storeKey(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+	return getLots(r.db, r.day.Date(time.Now()), "l.product_id = $2 AND l.store_id = $3", productID, key)
+}
+
+func (r *ProductRepository) CreateVariant(variant *models.ProductVariant, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockProduct(tx, variant.ProductID); err != nil {
+		return err
+	}
+	key, err := storeKey(tx, actor.StoreID)
+	if err != nil {
+		return err
+	}
+	if err := r.insertVariant(tx, variant, key); err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "product_variant", variant.ID, nil, variant); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *ProductRepository) Delete(id int) error {
-	query := "DELETE FROM products WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+func (r *ProductRepository) UpdateVariant(variant *models.ProductVariant, actor models.Actor) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	before, err := lockVariant(tx, variant.ProductID, variant.ID)
+	if err != nil {
+		return err
+	}
+
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE product_variants SET sku = $1, name = $2, options = $3, price = $4, cost_price = $5, updated_at = NOW() WHERE id = $6"
+	_, err = tx.Exec(query, variant.SKU, variant.Name, options, variant.Price, variant.CostPrice, variant.ID)
+	if err != nil {
+		return err
+	}
+	key, err := storeKey(tx, actor.StoreID)
+	if err != nil {
+		return err
+	}
+	stock, err := storeStock(tx, variant.ProductID, variant.ID, key)
+	if err != nil {
+		return err
+	}
+	adjustment := stockReference{models.StockMovementAdjustment, "product_variant", variant.ID, key}
+	if _, err := r.ledger.adjust(tx, variant.ProductID, variant.ID, variant.Stock-stock, adjustment); err != nil {
+		return err
+	}
+	variant.AvailableStock = variant.Stock
+
+	if err := writeAudit(tx, actor, AuditUpdate, "product_variant", variant.ID, before, variant); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ProductRepository) DeleteVariant(productID, id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockVariant(tx, productID, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM product_variants WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return errors.New("product not found")
+
+	if err := writeAudit(tx, actor, AuditDelete, "product_variant", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getVariants loads variants keyed by product id, with their price and
+// stock at the store keyed by the first arg. clause is appended after the
+// FROM product_variants v, to join or filter.
+func getVariants(q querier, clause string, args ...interface{}) (map[int][]models.ProductVariant, error) {
+	stock := storeStockExpr("v.stock", "v.product_id", "v.id", "$1")
+	query := `SELECT v.id, v.product_id, v.sku, v.name, v.options, ` + storePriceExpr("v.price", "v.product_id", "v.id", "$1") + `, v.cost_price,
+		` + stock + `, ` + stock + ` - ` + variantReservedExpr("v.id", "''", "$1") + `
+		FROM product_variants v ` + clause + " ORDER BY v.product_id, v.id"
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := make(map[int][]models.ProductVariant)
+	for rows.Next() {
+		var v models.ProductVariant
+		var options []byte
+		err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &v.Name, &options, &v.Price, &v.CostPrice, &v.Stock, &v.AvailableStock)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &v.Options); err != nil {
+			return nil, err
+		}
+		variants[v.ProductID] = append(variants[v.ProductID], v)
+	}
+
+	return variants, rows.Err()
+}
+
+// attachVariants puts variants on their parent; a parent's stock is then
+// the sum of its variants' stock.
+func attachVariants(product *models.Product, variants []models.ProductVariant) {
+	if len(variants) == 0 {
+		return
+	}
+	product.Variants = variants
+	product.Stock, product.AvailableStock = 0, 0
+	for _, v := range variants {
+		product.Stock += v.Stock
+		product.AvailableStock += v.AvailableStock
+	}
+}
+
+// insertVariant adds a variant and books its opening stock at the store
+// keyed by store; lot-tracked stock goes into the lot without a number.
+func (r *ProductRepository) insertVariant(tx *sql.Tx, variant *models.ProductVariant, store int) error {
+	if variant.Options == nil {
+		variant.Options = map[string]string{}
+	}
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO product_variants (product_id, sku, name, options, price, cost_price, stock) VALUES ($1, $2, $3, $4, $5, $6, 0) RETURNING id"
+	err = tx.QueryRow(query, variant.ProductID, variant.SKU, variant.Name, options, variant.Price, variant.CostPrice).Scan(&variant.ID)
+	if err != nil {
+		return err
 	}
+	opening := stockReference{models.StockMovementAdjustment, "product_variant", variant.ID, store}
+	if _, err := r.ledger.adjust(tx, variant.ProductID, variant.ID, variant.Stock, opening); err != nil {
+		return err
+	}
+	variant.AvailableStock = variant.Stock
 	return nil
 }
+
+func lockVariant(tx *sql.Tx, productID, id int) (*models.ProductVariant, error) {
+	query := "SELECT id, product_id, sku, name, options, price, cost_price, stock FROM product_variants WHERE id = $1 AND product_id = $2 FOR UPDATE"
+
+	var variant models.ProductVariant
+	var options []byte
+	err := tx.QueryRow(query, id, productID).Scan(&variant.ID, &variant.ProductID, &variant.SKU, &variant.Name, &options, &variant.Price, &variant.CostPrice, &variant.Stock)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("variant id %d not found for product id %d", id, productID)
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(options, &variant.Options); err != nil {
+		return nil, err
+	}
+	variant.AvailableStock = variant.Stock
+
+	return &variant, nil
+}
+
+// SetComponents replaces a product's recipe. Recipes are one level deep:
+// a component cannot be composite itself, and a product with variants
+// cannot be composite.
+func (r *ProductRepository) SetComponents(productID int, components []models.ProductComponent, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	product, err := lockProduct(tx, productID)
+	if err != nil {
+		return err
+	}
+	before, err := getComponents(tx, "WHERE pc.product_id = $2", 0, productID)
+	if err != nil {
+		return err
+	}
+
+	if len(components) > 0 {
+		var hasVariants, isComponent bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1),
+			EXISTS (SELECT 1 FROM product_components WHERE component_id = $1)`, productID).Scan(&hasVariants, &isComponent)
+		if err != nil {
+			return err
+		}
+		if hasVariants {
+			return fmt.Errorf("%s has variants and cannot be composite", product.Name)
+		}
+		if isComponent {
+			return fmt.Errorf("%s is a component of another product and cannot be composite", product.Name)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM product_components WHERE product_id = $1", productID); err != nil {
+		return err
+	}
+	for _, c := range components {
+		if c.ComponentID == productID {
+			return errors.New("a product cannot be its own component")
+		}
+		var composite bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM product_components WHERE product_id = $1) FROM products WHERE id = $1", c.ComponentID).Scan(&composite)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("component product id %d not found", c.ComponentID)
+		}
+		if err != nil {
+			return err
+		}
+		if composite {
+			return fmt.Errorf("component product id %d is composite itself", c.ComponentID)
+		}
+		_, err = tx.Exec("INSERT INTO product_components (product_id, component_id, quantity) VALUES ($1, $2, $3)", productID, c.ComponentID, c.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "product_components", productID, before[productID], components); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getComponents loads recipes keyed by product id, with the components'
+// stock at the store keyed by the first arg. clause filters the
+// product_components pc rows.
+func getComponents(q querier, clause string, args ...interface{}) (map[int][]models.ProductComponent, error) {
+	query := `SELECT pc.product_id, pc.component_id, c.name, pc.quantity, c.cost_price,
+		` + storeStockExpr("c.stock", "c.id", "0", "$1") + ` - ` + reservedExpr("c.id", "''", "$1") + `
+		FROM product_components pc
+		JOIN products c ON c.id = pc.component_id ` + clause + " ORDER BY pc.product_id, c.name"
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	components := make(map[int][]models.ProductComponent)
+	for rows.Next() {
+		var productID int
+		var c models.ProductComponent
+		if err := rows.Scan(&productID, &c.ComponentID, &c.Name, &c.Quantity, &c.CostPrice, &c.AvailableStock); err != nil {
+			return nil, err
+		}
+		components[productID] = append(components[productID], c)
+	}
+
+	return components, rows.Err()
+}
+
+// attachComponents puts the recipe on a composite product and derives its
+// cost and how many units the components on hand can make.
+func attachComponents(product *models.Product, components []models.ProductComponent) {
+	if len(components) == 0 {
+		return
+	}
+	product.Components = components
+	product.CostPrice = 0
+	for i, c := range components {
+		product.CostPrice += c.CostPrice * c.Quantity
+		makeable := 0
+		if c.Quantity > 0 {
+			makeable = c.AvailableStock / c.Quantity
+		}
+		if i == 0 || makeable < product.AvailableStock {
+			product.AvailableStock = makeable
+		}
+	}
+	if product.AvailableStock < 0 {
+		product.AvailableStock = 0
+	}
+	product.Stock = product.AvailableStock
+}
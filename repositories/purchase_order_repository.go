@@ -0,0 +1,442 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"strings"
+)
+
+type PurchaseOrderRepository struct {
+	db     *sql.DB
+	ledger *StockLedger
+}
+
+func NewPurchaseOrderRepository(db *sql.DB, ledger *StockLedger) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db, ledger: ledger}
+}
+
+const purchaseOrderSelect = `SELECT po.id, po.supplier_id, s.name, po.status, po.note, COALESCE(po.created_by, 0), po.created_at, po.sent_at, po.closed_at,
+	COALESCE((SELECT SUM(i.quantity * i.unit_cost) FROM purchase_order_items i WHERE i.purchase_order_id = po.id), 0)
+	FROM purchase_orders po
+	JOIN suppliers s ON s.id = po.supplier_id`
+
+func scanPurchaseOrder(row rowScanner, po *models.PurchaseOrder) error {
+	return row.Scan(&po.ID, &po.SupplierID, &po.SupplierName, &po.Status, &po.Note, &po.CreatedBy, &po.CreatedAt, &po.SentAt, &po.ClosedAt, &po.TotalCost)
+}
+
+func (r *PurchaseOrderRepository) GetAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.SupplierID != 0 {
+		args = append(args, filter.SupplierID)
+		conditions = append(conditions, fmt.Sprintf("po.supplier_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("po.status = $%d", len(args)))
+	}
+
+	query := purchaseOrderSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY po.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]models.PurchaseOrder, 0)
+	for rows.Next() {
+		var po models.PurchaseOrder
+		if err := scanPurchaseOrder(rows, &po); err != nil {
+			return nil, err
+		}
+		orders = append(orders, po)
+	}
+
+	return orders, rows.Err()
+}
+
+func (r *PurchaseOrderRepository) GetByID(id int) (*models.PurchaseOrder, error) {
+	return getPurchaseOrder(r.db, id, false)
+}
+
+func (r *PurchaseOrderRepository) Create(po *models.PurchaseOrder, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockSupplier(tx, po.SupplierID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO purchase_orders (supplier_id, status, note, created_by) VALUES ($1, $2, $3, NULLIF($4, 0)) RETURNING id"
+	err = tx.QueryRow(query, po.SupplierID, models.PurchaseOrderDraft, po.Note, actor.UserID).Scan(&po.ID)
+	if err != nil {
+		return err
+	}
+	if err := insertPurchaseOrderItems(tx, po.ID, po.SupplierID, po.Items); err != nil {
+		return err
+	}
+
+	created, err := getPurchaseOrder(tx, po.ID, false)
+	if err != nil {
+		return err
+	}
+	*po = *created
+
+	if err := writeAudit(tx, actor, AuditCreate, "purchase_order", po.ID, nil, po); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update replaces the note and items of a draft.
+func (r *PurchaseOrderRepository) Update(po *models.PurchaseOrder, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getPurchaseOrder(tx, po.ID, true)
+	if err != nil {
+		return err
+	}
+	if before.Status != models.PurchaseOrderDraft {
+		return fmt.Errorf("purchase order %d is %s, only drafts can be edited", po.ID, before.Status)
+	}
+
+	_, err = tx.Exec("UPDATE purchase_orders SET note = $1 WHERE id = $2", po.Note, po.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM purchase_order_items WHERE purchase_order_id = $1", po.ID)
+	if err != nil {
+		return err
+	}
+	if err := insertPurchaseOrderItems(tx, po.ID, before.SupplierID, po.Items); err != nil {
+		return err
+	}
+
+	after, err := getPurchaseOrder(tx, po.ID, false)
+	if err != nil {
+		return err
+	}
+	*po = *after
+
+	if err := writeAudit(tx, actor, AuditUpdate, "purchase_order", po.ID, before, po); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PurchaseOrderRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getPurchaseOrder(tx, id, true)
+	if err != nil {
+		return err
+	}
+	if before.Status != models.PurchaseOrderDraft {
+		return fmt.Errorf("purchase order %d is %s, only drafts can be deleted", id, before.Status)
+	}
+
+	_, err = tx.Exec("DELETE FROM purchase_order_items WHERE purchase_order_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM purchase_orders WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "purchase_order", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Send marks a draft as sent to the supplier; it can then be received
+// against but no longer edited.
+func (r *PurchaseOrderRepository) Send(id int, actor models.Actor) (*models.PurchaseOrder, error) {
+	return r.transition(id, actor, []string{models.PurchaseOrderDraft}, models.PurchaseOrderSent, "sent_at = NOW()")
+}
+
+// Close stops receiving against an order, e.g. when the supplier cannot
+// deliver the rest.
+func (r *PurchaseOrderRepository) Close(id int, actor models.Actor) (*models.PurchaseOrder, error) {
+	return r.transition(id, actor, []string{models.PurchaseOrderSent, models.PurchaseOrderPartiallyReceived}, models.PurchaseOrderClosed, "closed_at = NOW()")
+}
+
+func (r *PurchaseOrderRepository) transition(id int, actor models.Actor, from []string, to, set string) (*models.PurchaseOrder, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getPurchaseOrder(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || before.Status == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("purchase order %d is %s and cannot be marked %s", id, before.Status, to)
+	}
+
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $1, "+set+" WHERE id = $2", to, id)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := getPurchaseOrder(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "purchase_order", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// Receive posts a goods receipt against a sent order. Items left without
+// a cost price take the order's unit cost. The order is closed once every
+// line has fully arrived.
+func (r *PurchaseOrderRepository) Receive(id int, receipt *models.GoodsReceipt, actor models.Actor) (*models.PurchaseOrder, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getPurchaseOrder(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != models.PurchaseOrderSent && before.Status != models.PurchaseOrderPartiallyReceived {
+		return nil, fmt.Errorf("purchase order %d is %s and cannot be received against", id, before.Status)
+	}
+	po := *before
+	po.Items = append([]models.PurchaseOrderItem(nil), before.Items...)
+
+	lines := make(map[[2]int]*models.PurchaseOrderItem, len(po.Items))
+	for i := range po.Items {
+		item := &po.Items[i]
+		lines[[2]int{item.ProductID, item.VariantID}] = item
+	}
+	for i := range receipt.Items {
+		item := &receipt.Items[i]
+		line, ok := lines[[2]int{item.ProductID, item.VariantID}]
+		if !ok {
+			return nil, fmt.Errorf("product id %d variant id %d is not on purchase order %d", item.ProductID, item.VariantID, id)
+		}
+		if line.ReceivedQuantity+item.Quantity > line.Quantity {
+			return nil, fmt.Errorf("receiving %d of %s exceeds the %d still on order", item.Quantity, line.Name, line.Quantity-line.ReceivedQuantity)
+		}
+		if item.CostPrice == 0 {
+			item.CostPrice = line.UnitCost
+		}
+		line.ReceivedQuantity += item.Quantity
+		_, err = tx.Exec("UPDATE purchase_order_items SET received_quantity = received_quantity + $1 WHERE purchase_order_id = $2 AND product_id = $3 AND COALESCE(variant_id, 0) = $4",
+			item.Quantity, id, item.ProductID, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	receipt.PurchaseOrderID = id
+	receipt.SupplierID = po.SupplierID
+	if err := postGoodsReceipt(tx, r.ledger, receipt, actor); err != nil {
+		return nil, err
+	}
+
+	status := models.PurchaseOrderClosed
+	for _, line := range po.Items {
+		if line.ReceivedQuantity < line.Quantity {
+			status = models.PurchaseOrderPartiallyReceived
+		}
+	}
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $1, closed_at = CASE WHEN $1 = $2 THEN NOW() END WHERE id = $3",
+		status, models.PurchaseOrderClosed, id)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := getPurchaseOrder(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "purchase_order", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// Suggestions drafts an order per supplier for linked products whose
+// stock at a store, counting what is already on order, has fallen to
+// their minimum. Each product is ordered from its cheapest supplier, up to
+// the maximum stock. Nothing is saved.
+func (r *PurchaseOrderRepository) Suggestions(supplierID, storeID int) ([]models.PurchaseOrder, error) {
+	key, err := storeKey(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT DISTINCT ON (sp.product_id, sp.variant_id)
+			sp.supplier_id, s.name, sp.product_id, sp.variant_id, p.name, COALESCE(v.name, ''), sp.supplier_sku, sp.last_cost,
+			sp.min_stock, sp.max_stock, `+storeStockExpr("COALESCE(v.stock, p.stock)", "sp.product_id", "sp.variant_id", "$4")+`,
+			COALESCE((SELECT SUM(i.quantity - i.received_quantity) FROM purchase_order_items i
+				JOIN purchase_orders po ON po.id = i.purchase_order_id
+				WHERE po.status IN ($1, $2, $3) AND i.product_id = sp.product_id AND COALESCE(i.variant_id, 0) = sp.variant_id), 0)
+		FROM supplier_products sp
+		JOIN suppliers s ON s.id = sp.supplier_id
+		JOIN products p ON p.id = sp.product_id
+		LEFT JOIN product_variants v ON v.id = sp.variant_id
+		WHERE sp.max_stock > 0 OR sp.min_stock > 0
+		ORDER BY sp.product_id, sp.variant_id, sp.last_cost, sp.supplier_id`,
+		models.PurchaseOrderDraft, models.PurchaseOrderSent, models.PurchaseOrderPartiallyReceived, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]models.PurchaseOrder, 0)
+	bySupplier := make(map[int]int)
+	for rows.Next() {
+		var supplier int
+		var supplierName, variant string
+		var item models.PurchaseOrderItem
+		var minStock, maxStock, stock, onOrder int
+		err := rows.Scan(&supplier, &supplierName, &item.ProductID, &item.VariantID, &item.Name, &variant, &item.SupplierSKU, &item.UnitCost,
+			&minStock, &maxStock, &stock, &onOrder)
+		if err != nil {
+			return nil, err
+		}
+		if supplierID != 0 && supplier != supplierID {
+			continue
+		}
+		if stock+onOrder > minStock {
+			continue
+		}
+		item.Quantity = max(maxStock, minStock) - stock - onOrder
+		if item.Quantity <= 0 {
+			continue
+		}
+		if variant != "" {
+			item.Name += " (" + variant + ")"
+		}
+
+		i, ok := bySupplier[supplier]
+		if !ok {
+			i = len(orders)
+			bySupplier[supplier] = i
+			orders = append(orders, models.PurchaseOrder{SupplierID: supplier, SupplierName: supplierName, Status: models.PurchaseOrderDraft})
+		}
+		orders[i].Items = append(orders[i].Items, item)
+		orders[i].TotalCost += item.Quantity * item.UnitCost
+	}
+
+	return orders, rows.Err()
+}
+
+// insertPurchaseOrderItems stores order lines, taking the supplier's last
+// cost for lines without a unit cost.
+func insertPurchaseOrderItems(tx *sql.Tx, poID, supplierID int, items []models.PurchaseOrderItem) error {
+	for _, item := range items {
+		unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: item.ProductID, VariantID: item.VariantID}, "", false, 0)
+		if err != nil {
+			return err
+		}
+		if len(unit.components) > 0 {
+			return fmt.Errorf("%s is made from components, order the components instead", unit.name)
+		}
+		if item.UnitCost == 0 {
+			err := tx.QueryRow("SELECT last_cost FROM supplier_products WHERE supplier_id = $1 AND product_id = $2 AND variant_id = $3",
+				supplierID, unit.productID, item.VariantID).Scan(&item.UnitCost)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+		}
+		_, err = tx.Exec("INSERT INTO purchase_order_items (purchase_order_id, product_id, variant_id, quantity, unit_cost) VALUES ($1, $2, NULLIF($3, 0), $4, $5)",
+			poID, unit.productID, item.VariantID, item.Quantity, item.UnitCost)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPurchaseOrder reads an order with its lines, locking the order row
+// when lock is true.
+func getPurchaseOrder(q querier, id int, lock bool) (*models.PurchaseOrder, error) {
+	query := purchaseOrderSelect + " WHERE po.id = $1"
+	if lock {
+		query += " FOR UPDATE OF po"
+	}
+
+	var po models.PurchaseOrder
+	err := scanPurchaseOrder(q.QueryRow(query, id), &po)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("purchase order not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`SELECT i.product_id, COALESCE(i.variant_id, 0), COALESCE(p.name, ''), COALESCE(v.name, ''), COALESCE(sp.supplier_sku, ''),
+			i.quantity, i.received_quantity, i.unit_cost
+		FROM purchase_order_items i
+		LEFT JOIN products p ON p.id = i.product_id
+		LEFT JOIN product_variants v ON v.id = i.variant_id
+		LEFT JOIN supplier_products sp ON sp.supplier_id = $2 AND sp.product_id = i.product_id AND sp.variant_id = COALESCE(i.variant_id, 0)
+		WHERE i.purchase_order_id = $1
+		ORDER BY i.id`, id, po.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	po.Items = make([]models.PurchaseOrderItem, 0)
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		var variant string
+		err := rows.Scan(&item.ProductID, &item.VariantID, &item.Name, &variant, &item.SupplierSKU, &item.Quantity, &item.ReceivedQuantity, &item.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			item.Name += " (" + variant + ")"
+		}
+		po.Items = append(po.Items, item)
+	}
+
+	return &po, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultReceiptNumberFormat = "INV/{YYYY}/{MM}/{SEQ:6}"
+
+var seqToken = regexp.MustCompile(`\{SEQ(?::(\d+))?\}`)
+
+// ReceiptNumberGenerator hands out printable receipt numbers such as
+// INV/2026/10/000123. The format understands {STORE}, {YYYY}, {YY}, {MM},
+// {DD} and {SEQ} or {SEQ:n} for a zero padded sequence. The sequence
+// restarts every day, month or year (or never) and is kept per store. Dates
+// are business dates, so a sale after midnight but before the cutoff is
+// numbered with the day before.
+type ReceiptNumberGenerator struct {
+	format    string
+	storeCode string
+	reset     string
+	day       models.BusinessDay
+}
+
+func NewReceiptNumberGenerator(format, storeCode, reset string, day models.BusinessDay) (*ReceiptNumberGenerator, error) {
+	if format == "" {
+		format = DefaultReceiptNumberFormat
+	}
+	if !seqToken.MatchString(format) {
+		return nil, fmt.Errorf("receipt number format %q must contain {SEQ}", format)
+	}
+	if reset == "" {
+		reset = "month"
+	}
+	// A sequence that restarts must be told apart by the period it
+	// restarts in, or two receipts would print the same number.
+	var needs []string
+	switch reset {
+	case "day":
+		needs = []string{"{DD}", "{MM}"}
+	case "month":
+		needs = []string{"{MM}"}
+	case "year":
+	case "never":
+	default:
+		return nil, fmt.Errorf("invalid receipt sequence reset %q, use day, month, year or never", reset)
+	}
+	if reset != "never" && !strings.Contains(format, "{YYYY}") && !strings.Contains(format, "{YY}") {
+		needs = append(needs, "{YYYY}")
+	}
+	for _, token := range needs {
+		if !strings.Contains(format, token) {
+			return nil, fmt.Errorf("receipt number format %q must contain %s when the sequence resets every %s", format, token, reset)
+		}
+	}
+
+	return &ReceiptNumberGenerator{format: format, storeCode: storeCode, reset: reset, day: day}, nil
+}
+
+// Next reserves the next sequence value inside tx, so a rolled back
+// checkout gives its number back and concurrent checkouts never share one.
+// storeCode overrides the configured store code when set; the format must
+// then print the store code too.
+func (g *ReceiptNumberGenerator) Next(tx *sql.Tx, now time.Time, storeCode string) (string, error) {
+	if storeCode == "" {
+		storeCode = g.storeCode
+	}
+	if storeCode != g.storeCode && !strings.Contains(g.format, "{STORE}") {
+		return "", fmt.Errorf("receipt number format %q must contain {STORE} to number the receipts of more than one store", g.format)
+	}
+	date := g.day.Date(now)
+	var seq int
+	query := `INSERT INTO receipt_sequences (scope, last_value) VALUES ($1, 1)
+		ON CONFLICT (scope) DO UPDATE SET last_value = receipt_sequences.last_value + 1
+		RETURNING last_value`
+	err := tx.QueryRow(query, g.scope(date, storeCode)).Scan(&seq)
+	if err != nil {
+		return "", err
+	}
+
+	return g.render(date, storeCode, seq), nil
+}
+
+func (g *ReceiptNumberGenerator) scope(date time.Time, storeCode string) string {
+	period := ""
+	switch g.reset {
+	case "day":
+		period = date.Format("2006-01-02")
+	case "month":
+		period = date.Format("2006-01")
+	case "year":
+		period = date.Format("2006")
+	}
+	return storeCode + "|" + period
+}
+
+func (g *ReceiptNumberGenerator) render(date time.Time, storeCode string, seq int) string {
+	number := strings.NewReplacer(
+		"{STORE}", storeCode,
+		"{YYYY}", date.Format("2006"),
+		"{YY}", date.Format("06"),
+		"{MM}", date.Format("01"),
+		"{DD}", date.Format("02"),
+	).Replace(g.format)
+
+	return seqToken.ReplaceAllStringFunc(number, func(token string) string {
+		width := 0
+		if m := seqToken.FindStringSubmatch(token); m[1] != "" {
+			width, _ = strconv.Atoi(m[1])
+		}
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
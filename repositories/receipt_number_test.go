@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+	"time"
+)
+
+func TestNewReceiptNumberGenerator(t *testing.T) {
+	tests := []struct {
+		format, reset string
+		ok            bool
+	}{
+		{"", "", true},
+		{"INV/{YYYY}/{MM}/{SEQ:6}", "month", true},
+		{"{STORE}-{YY}{MM}{DD}-{SEQ:4}", "day", true},
+		{"R{YYYY}-{SEQ}", "year", true},
+		{"R-{SEQ:8}", "never", true},
+		{"INV/{YYYY}/{MM}", "month", false},
+		{"INV/{YYYY}/{SEQ:6}", "month", false},
+		{"INV/{MM}/{SEQ:6}", "month", false},
+		{"INV/{YYYY}/{MM}/{SEQ:6}", "day", false},
+		{"INV/{SEQ:6}", "year", false},
+		{"INV/{YYYY}/{MM}/{SEQ:6}", "week", false},
+	}
+	for _, tt := range tests {
+		_, err := NewReceiptNumberGenerator(tt.format, "S1", tt.reset, models.BusinessDay{Location: time.UTC})
+		if (err == nil) != tt.ok {
+			t.Errorf("NewReceiptNumberGenerator(%q, %q) error = %v, want ok %v", tt.format, tt.reset, err, tt.ok)
+		}
+	}
+}
+
+func TestReceiptNumberRenderAndScope(t *testing.T) {
+	date := time.Date(2026, time.March, 7, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format, reset string
+		seq           int
+		number, scope string
+	}{
+		{"", "", 123, "INV/2026/03/000123", "S1|2026-03"},
+		{"{STORE}-{YY}{MM}{DD}-{SEQ:4}", "day", 7, "S1-260307-0007", "S1|2026-03-07"},
+		{"R{YYYY}-{SEQ}", "year", 42, "R2026-42", "S1|2026"},
+		{"R-{SEQ:3}", "never", 12345, "R-12345", "S1|"},
+	}
+	for _, tt := range tests {
+		g, err := NewReceiptNumberGenerator(tt.format, "S1", tt.reset, models.BusinessDay{Location: time.UTC})
+		if err != nil {
+			t.Fatalf("NewReceiptNumberGenerator(%q, %q): %v", tt.format, tt.reset, err)
+		}
+		if got := g.render(date, "S1", tt.seq); got != tt.number {
+			t.Errorf("format %q renders %d as %q, want %q", tt.format, tt.seq, got, tt.number)
+		}
+		if got := g.scope(date, "S1"); got != tt.scope {
+			t.Errorf("format %q reset %q has scope %q, want %q", tt.format, tt.reset, got, tt.scope)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ReportRepository struct {
+	db     *sql.DB
+	ledger *StockLedger
+	day    models.BusinessDay
+}
+
+func NewReportRepository(db *sql.DB, ledger *StockLedger, day models.BusinessDay) *ReportRepository {
+	return &ReportRepository{db: db, ledger: ledger, day: day}
+}
+
+// BusinessDay is the business day reports group sales by.
+func (r *ReportRepository) BusinessDay() models.BusinessDay {
+	return r.day
+}
+
+// businessTimeExpr is col on the wall clock of the business day: in the
+// store's time zone, or the database's when none is configured, moved back
+// by the cutoff so that its date is the business date.
+func businessTimeExpr(col string, day models.BusinessDay) string {
+	zone := "current_setting('TimeZone')"
+	if day.Zone() != "" {
+		zone = pq.QuoteLiteral(day.Zone())
+	}
+	return fmt.Sprintf("((%s AT TIME ZONE %s) - interval '%d seconds')", col, zone, int(day.Cutoff.Seconds()))
+}
+
+// profitGroups maps a group_by value to the key and name columns of the
+// profit query. {sold_at} stands for the business time of the sale.
+var profitGroups = map[string][2]string{
+	"product":  {"d.product_id::text", "COALESCE(p.name, '')"},
+	"category": {"COALESCE(p.category_id, 0)::text", "COALESCE(c.name, 'Uncategorized')"},
+	"day":      {"TO_CHAR({sold_at}, 'YYYY-MM-DD')", "TO_CHAR({sold_at}, 'YYYY-MM-DD')"},
+	"month":    {"TO_CHAR({sold_at}, 'YYYY-MM')", "TO_CHAR({sold_at}, 'YYYY-MM')"},
+	"store":    {storeIDExpr("t.store_id") + "::text", storeNameExpr("t.store_id")},
+}
+
+// storeFilter turns the store_id filter of a report into a store key, or
+// -1 for all stores when storeID is 0.
+func storeFilter(q querier, storeID int) (int, error) {
+	if storeID == 0 {
+		return -1, nil
+	}
+	return storeKey(q, storeID)
+}
+
+// Profit totals revenue and the cost snapshotted on each sold line between
+// from and to, leaving out refunded sales. A storeID other than 0 limits it
+// to that store.
+func (r *ReportRepository) Profit(from, to time.Time, groupBy string, storeID int) (*models.ProfitReport, error) {
+	group, ok := profitGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown group_by %q, use product, category, day, month or store", groupBy)
+	}
+	store, err := storeFilter(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s, SUM(d.quantity), SUM(d.subtotal), SUM(COALESCE(d.cost, 0))
+		FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		LEFT JOIN products p ON p.id = d.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.refunded_at IS NULL
+			AND ($3 < 0 OR COALESCE(t.store_id, 0) = $3)
+		GROUP BY 1, 2
+		ORDER BY 1`, group[0], group[1])
+	query = strings.ReplaceAll(query, "{sold_at}", businessTimeExpr("t.created_at", r.day))
+	rows, err := r.db.Query(query, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.ProfitReport{From: from, To: to, GroupBy: groupBy, Lines: make([]models.ProfitLine, 0)}
+	for rows.Next() {
+		var line models.ProfitLine
+		if err := rows.Scan(&line.Key, &line.Name, &line.Quantity, &line.Revenue, &line.COGS); err != nil {
+			return nil, err
+		}
+		line.GrossProfit = line.Revenue - line.COGS
+		line.Margin = margin(line.GrossProfit, line.Revenue)
+		report.Revenue += line.Revenue
+		report.COGS += line.COGS
+		report.Lines = append(report.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.GrossProfit = report.Revenue - report.COGS
+	report.Margin = margin(report.GrossProfit, report.Revenue)
+	return report, nil
+}
+
+// InventoryValuation sums the stock ledger up to asOf, for all stores or,
+// when storeID is not 0, for one. Stock that has never moved through the
+// ledger is the default store's and is counted as it stands now, at its
+// cost price.
+func (r *ReportRepository) InventoryValuation(asOf time.Time, storeID int) (*models.InventoryValuation, error) {
+	store, err := storeFilter(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT m.product_id, COALESCE(m.variant_id, 0), COALESCE(p.name, ''), COALESCE(v.name, ''), SUM(m.quantity), SUM(m.value)
+		FROM stock_movements m
+		LEFT JOIN products p ON p.id = m.product_id
+		LEFT JOIN product_variants v ON v.id = m.variant_id
+		WHERE m.created_at < $1 AND ($2 < 0 OR COALESCE(m.store_id, 0) = $2)
+		GROUP BY 1, 2, 3, 4
+		HAVING SUM(m.quantity) <> 0 OR SUM(m.value) <> 0
+		UNION ALL
+		SELECT p.id, 0, p.name, '', p.stock, p.stock * p.cost_price
+		FROM products p
+		WHERE p.stock > 0 AND $2 <= 0
+			AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id AND m.variant_id IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+			AND NOT EXISTS (SELECT 1 FROM product_components c WHERE c.product_id = p.id)
+		UNION ALL
+		SELECT v.product_id, v.id, p.name, v.name, v.stock, v.stock * v.cost_price
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		WHERE v.stock > 0 AND $2 <= 0 AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.variant_id = v.id)
+		ORDER BY 3, 4`, asOf, store)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	valuation := &models.InventoryValuation{AsOf: asOf, Method: r.ledger.Method(), Lines: make([]models.ValuationLine, 0)}
+	for rows.Next() {
+		var line models.ValuationLine
+		var variant string
+		if err := rows.Scan(&line.ProductID, &line.VariantID, &line.Name, &variant, &line.Quantity, &line.Value); err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			line.Name += " (" + variant + ")"
+		}
+		if line.Quantity != 0 {
+			line.UnitCost = line.Value / line.Quantity
+		}
+		valuation.TotalQuantity += line.Quantity
+		valuation.TotalValue += line.Value
+		valuation.Lines = append(valuation.Lines, line)
+	}
+
+	return valuation, rows.Err()
+}
+
+// StoreSales compares the stores' sales between from and to, leaving out
+// refunded sales, best selling store first.
+func (r *ReportRepository) StoreSales(from, to time.Time) ([]models.StoreSales, error) {
+	rows, err := r.db.Query(`SELECT `+storeIDExpr("t.store_id")+`, `+storeNameExpr("t.store_id")+`, COUNT(*), SUM(t.total_amount),
+			SUM(COALESCE((SELECT SUM(COALESCE(d.cost, 0)) FROM transaction_details d WHERE d.transaction_id = t.id), 0))
+		FROM transactions t
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.refunded_at IS NULL
+		GROUP BY 1, 2
+		ORDER BY 4 DESC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := make([]models.StoreSales, 0)
+	for rows.Next() {
+		var s models.StoreSales
+		if err := rows.Scan(&s.StoreID, &s.StoreName, &s.Transactions, &s.Revenue, &s.COGS); err != nil {
+			return nil, err
+		}
+		s.GrossProfit = s.Revenue - s.COGS
+		s.Margin = margin(s.GrossProfit, s.Revenue)
+		if s.Transactions > 0 {
+			s.AverageSale = s.Revenue / s.Transactions
+		}
+		stores = append(stores, s)
+	}
+
+	return stores, rows.Err()
+}
+
+// rankingColumns maps a sort_by value to the column position of the
+// ranking queries, and rankingOrders an order to its direction.
+var (
+	rankingColumns = map[string]int{"quantity": 3, "revenue": 4, "profit": 6}
+	rankingOrders  = map[string]string{"top": "DESC", "bottom": "ASC"}
+)
+
+// rankingSales sums the sold lines between $1 and $2 at the store filter
+// $3, leaving out refunded sales.
+const rankingSales = `SELECT d.product_id, p.category_id, SUM(d.quantity) AS quantity, SUM(d.subtotal) AS revenue, SUM(COALESCE(d.cost, 0)) AS cost
+	FROM transaction_details d
+	JOIN transactions t ON t.id = d.transaction_id
+	LEFT JOIN products p ON p.id = d.product_id
+	WHERE t.created_at >= $1 AND t.created_at < $2 AND t.refunded_at IS NULL
+		AND ($3 < 0 OR COALESCE(t.store_id, 0) = $3)
+	GROUP BY d.product_id, p.category_id`
+
+// ProductRanking ranks the products in the catalog, optionally of one
+// category, by their sales in the period.
+func (r *ReportRepository) ProductRanking(filter models.RankingFilter) (*models.SalesRanking, error) {
+	return r.ranking(filter, `WITH sales AS (`+rankingSales+`)
+		SELECT p.id, p.name, COALESCE(s.quantity, 0), COALESCE(s.revenue, 0), COALESCE(s.cost, 0), COALESCE(s.revenue - s.cost, 0)
+		FROM products p
+		LEFT JOIN sales s ON s.product_id = p.id
+		WHERE $4 = 0 OR p.category_id = $4`)
+}
+
+// CategoryRanking ranks the categories, and the products without one, by
+// their sales in the period.
+func (r *ReportRepository) CategoryRanking(filter models.RankingFilter) (*models.SalesRanking, error) {
+	return r.ranking(filter, `WITH sales AS (`+rankingSales+`),
+		categorized AS (SELECT category_id, SUM(quantity) AS quantity, SUM(revenue) AS revenue, SUM(cost) AS cost FROM sales GROUP BY category_id)
+		SELECT c.id, c.name, COALESCE(s.quantity, 0), COALESCE(s.revenue, 0), COALESCE(s.cost, 0), COALESCE(s.revenue - s.cost, 0)
+		FROM categories c
+		LEFT JOIN categorized s ON s.category_id = c.id
+		WHERE $4 = 0 OR c.id = $4
+		UNION ALL
+		SELECT 0, 'Uncategorized', s.quantity, s.revenue, s.cost, s.revenue - s.cost
+		FROM categorized s
+		WHERE s.category_id IS NULL AND $4 = 0`)
+}
+
+func (r *ReportRepository) ranking(filter models.RankingFilter, query string) (*models.SalesRanking, error) {
+	column, ok := rankingColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort_by %q, use quantity, revenue or profit", filter.SortBy)
+	}
+	direction, ok := rankingOrders[filter.Order]
+	if !ok {
+		return nil, fmt.Errorf("unknown order %q, use top or bottom", filter.Order)
+	}
+	store, err := storeFilter(r.db, filter.StoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf("SELECT * FROM (%s) ranked ORDER BY %d %s, 2 LIMIT $5", query, column, direction)
+	rows, err := r.db.Query(query, filter.From, filter.To, store, filter.CategoryID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranking := &models.SalesRanking{From: filter.From, To: filter.To, SortBy: filter.SortBy, Order: filter.Order, Lines: make([]models.RankingLine, 0)}
+	for rows.Next() {
+		line := models.RankingLine{Rank: len(ranking.Lines) + 1}
+		if err := rows.Scan(&line.ID, &line.Name, &line.Quantity, &line.Revenue, &line.COGS, &line.GrossProfit); err != nil {
+			return nil, err
+		}
+		line.Margin = margin(line.GrossProfit, line.Revenue)
+		ranking.Lines = append(ranking.Lines, line)
+	}
+
+	return ranking, rows.Err()
+}
+
+// DeadStock lists products and variants with stock at the store filter
+// that have not been sold since, refunded sales aside. Selling a product
+// made from components counts as a sale of each component.
+func (r *ReportRepository) DeadStock(since time.Time, storeID int) ([]models.DeadStockLine, error) {
+	store, err := storeFilter(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	sold := `SELECT MAX(t.created_at) FROM transactions t WHERE t.refunded_at IS NULL AND ($1 < 0 OR COALESCE(t.store_id, 0) = $1) AND t.id IN `
+	rows, err := r.db.Query(`SELECT * FROM (
+			SELECT p.id, 0, p.name,
+				CASE WHEN $1 < 0 THEN p.stock ELSE `+storeStockExpr("p.stock", "p.id", "0", "$1")+` END AS stock, p.cost_price,
+				GREATEST(
+					(`+sold+`(SELECT d.transaction_id FROM transaction_details d WHERE d.product_id = p.id)),
+					(`+sold+`(SELECT d.transaction_id FROM transaction_details d JOIN transaction_detail_components dc ON dc.detail_id = d.id WHERE dc.component_id = p.id))
+				) AS last_sold
+			FROM products p
+			WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+			UNION ALL
+			SELECT v.product_id, v.id, p.name || ' (' || v.name || ')',
+				CASE WHEN $1 < 0 THEN v.stock ELSE `+storeStockExpr("v.stock", "v.product_id", "v.id", "$1")+` END, v.cost_price,
+				(`+sold+`(SELECT d.transaction_id FROM transaction_details d WHERE d.variant_id = v.id))
+			FROM product_variants v
+			JOIN products p ON p.id = v.product_id
+		) s
+		WHERE stock > 0 AND (last_sold IS NULL OR last_sold < $2)
+		ORDER BY stock * cost_price DESC, 3`, store, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]models.DeadStockLine, 0)
+	for rows.Next() {
+		var line models.DeadStockLine
+		var costPrice int
+		if err := rows.Scan(&line.ProductID, &line.VariantID, &line.Name, &line.Stock, &costPrice, &line.LastSoldAt); err != nil {
+			return nil, err
+		}
+		line.Value = line.Stock * costPrice
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+// salesBuckets truncates a time to the start of its bucket and steps from
+// one bucket to the next, in the time zone of the time. Times are on the
+// business day's wall clock, see BusinessDay.
+var salesBuckets = map[string]struct {
+	start  func(time.Time) time.Time
+	next   func(time.Time) time.Time
+	layout string
+}{
+	"hour": {
+		func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		},
+		func(t time.Time) time.Time { return t.Add(time.Hour) },
+		"2006-01-02 15:00",
+	},
+	"day": {
+		func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) },
+		func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		"2006-01-02",
+	},
+	"week": {
+		func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+		},
+		func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+		"2006-01-02",
+	},
+	"month": {
+		func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()) },
+		func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		"2006-01",
+	},
+}
+
+// Sales buckets the sales between from and to, leaving out refunded sales.
+// Days, weeks and months are business days of the store's time zone, not
+// the database server's, and buckets without sales are included so the
+// series has no gaps.
+func (r *ReportRepository) Sales(from, to time.Time, granularity string, storeID int) (*models.SalesReport, error) {
+	bucket, ok := salesBuckets[granularity]
+	if !ok {
+		return nil, fmt.Errorf("unknown granularity %q, use hour, day, week or month", granularity)
+	}
+	store, err := storeFilter(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// clock moves a time onto the business day's wall clock.
+	clock := func(t time.Time) time.Time { return t.In(r.day.Location).Add(-r.day.Cutoff) }
+	report := models.SalesReport{From: from, To: to, Granularity: granularity, TimeZone: r.day.Location.String(), Buckets: make([]models.SalesBucket, 0)}
+	index := make(map[time.Time]int)
+	for key := bucket.start(clock(from)); key.Add(r.day.Cutoff).Before(to); key = bucket.next(key) {
+		start := key.Add(r.day.Cutoff)
+		label := key.Format(bucket.layout)
+		if granularity == "hour" {
+			label = start.Format(bucket.layout)
+		}
+		index[key] = len(report.Buckets)
+		report.Buckets = append(report.Buckets, models.SalesBucket{Start: start, Label: label})
+	}
+
+	rows, err := r.db.Query(`SELECT t.created_at, t.total_amount,
+			COALESCE((SELECT SUM(d.quantity) FROM transaction_details d WHERE d.transaction_id = t.id), 0)
+		FROM transactions t
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.refunded_at IS NULL
+			AND ($3 < 0 OR COALESCE(t.store_id, 0) = $3)`, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := 0
+	for rows.Next() {
+		var createdAt time.Time
+		var amount, quantity int
+		if err := rows.Scan(&createdAt, &amount, &quantity); err != nil {
+			return nil, err
+		}
+		i, ok := index[bucket.start(clock(createdAt))]
+		if !ok {
+			continue
+		}
+		b := &report.Buckets[i]
+		b.Revenue += amount
+		b.Transactions++
+		b.Items += quantity
+		report.Revenue += amount
+		report.Transactions++
+		items += quantity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range report.Buckets {
+		b := &report.Buckets[i]
+		b.AverageSale, b.AverageItems = basket(b.Revenue, b.Items, b.Transactions)
+	}
+	report.AverageSale, report.AverageItems = basket(report.Revenue, items, report.Transactions)
+
+	return &report, nil
+}
+
+// basket is the average value and item count of a sale.
+func basket(revenue, items, sales int) (int, float64) {
+	if sales == 0 {
+		return 0, 0
+	}
+	return revenue / sales, math.Round(float64(items)/float64(sales)*100) / 100
+}
+
+// ExpiringLots lists lots with stock left that expire within days from
+// the current business date, including lots already expired.
+func (r *ReportRepository) ExpiringLots(days int) ([]models.ProductLot, error) {
+	return getLots(r.db, r.day.Date(time.Now()), "l.expires_at < $1::date + $2::int + 1", days)
+}
+
+// margin is profit as a percentage of revenue, rounded to two decimals.
+func margin(profit, revenue int) float64 {
+	if revenue == 0 {
+		return 0
+	}
+	return math.Round(float64(profit)/float64(revenue)*10000) / 100
+}
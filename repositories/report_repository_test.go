@@ -0,0 +1,26 @@
+package repositories
+
+import "testing"
+
+func TestMargin(t *testing.T) {
+	tests := []struct {
+		profit, revenue int
+		want            float64
+	}{
+		{0, 0, 0},
+		{500, 0, 0},
+		{0, 10000, 0},
+		{2500, 10000, 25},
+		{10000, 10000, 100},
+		{1, 3, 33.33},
+		{2, 3, 66.67},
+		{1, 8, 12.5},
+		{-1500, 10000, -15},
+		{-2, 3, -66.67},
+	}
+	for _, tt := range tests {
+		if got := margin(tt.profit, tt.revenue); got != tt.want {
+			t.Errorf("margin(%d, %d) = %v, want %v", tt.profit, tt.revenue, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"time"
+)
+
+// activeReservedQuery sums the reservations still holding a product or
+// variant at the store keyed by storeParam. refParam is a reference whose
+// own reservations are not counted.
+const activeReservedQuery = `COALESCE((
+	SELECT SUM(sr.quantity) FROM stock_reservations sr
+	WHERE %s AND COALESCE(sr.store_id, 0) = %s AND sr.released_at IS NULL AND sr.expires_at > NOW()
+	AND (%s = '' OR sr.reference <> %s)
+), 0)`
+
+func reservedExpr(productCol, refParam, storeParam string) string {
+	return fmt.Sprintf(activeReservedQuery, "sr.product_id = "+productCol+" AND sr.variant_id IS NULL", storeParam, refParam, refParam)
+}
+
+func variantReservedExpr(variantCol, refParam, storeParam string) string {
+	return fmt.Sprintf(activeReservedQuery, "sr.variant_id = "+variantCol, storeParam, refParam, refParam)
+}
+
+type ReservationRepository struct {
+	db *sql.DB
+}
+
+func NewReservationRepository(db *sql.DB) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+const reservationSelect = "SELECT id, product_id, COALESCE(variant_id, 0), COALESCE(store_id, 0), quantity, reference, expires_at, released_at, created_at FROM stock_reservations"
+
+func scanReservation(row rowScanner, res *models.StockReservation) error {
+	return row.Scan(&res.ID, &res.ProductID, &res.VariantID, &res.StoreID, &res.Quantity, &res.Reference, &res.ExpiresAt, &res.ReleasedAt, &res.CreatedAt)
+}
+
+func (r *ReservationRepository) GetActive(reference string) ([]models.StockReservation, error) {
+	query := reservationSelect + " WHERE released_at IS NULL AND expires_at > NOW()"
+	args := []interface{}{}
+	if reference != "" {
+		query += " AND reference = $1"
+		args = append(args, reference)
+	}
+	query += " ORDER BY expires_at"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := make([]models.StockReservation, 0)
+	for rows.Next() {
+		var res models.StockReservation
+		if err := scanReservation(rows, &res); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+func (r *ReservationRepository) Create(reference string, items []models.CheckoutItem, expiresAt time.Time, storeID int, actor models.Actor) ([]models.StockReservation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservations, err := reserveStock(tx, reference, items, expiresAt, storeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range reservations {
+		if err := writeAudit(tx, actor, AuditCreate, "stock_reservation", res.ID, nil, res); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (r *ReservationRepository) Release(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before models.StockReservation
+	err = scanReservation(tx.QueryRow(reservationSelect+" WHERE id = $1 AND released_at IS NULL FOR UPDATE", id), &before)
+	if err == sql.ErrNoRows {
+		return errors.New("reservation not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	after := before
+	err = tx.QueryRow("UPDATE stock_reservations SET released_at = NOW() WHERE id = $1 RETURNING released_at", id).Scan(&after.ReleasedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "stock_reservation", id, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseExpired marks every reservation past its expiry as released and
+// reports how many were swept.
+func (r *ReservationRepository) ReleaseExpired() (int64, error) {
+	query := "UPDATE stock_reservations SET released_at = expires_at WHERE released_at IS NULL AND expires_at <= NOW()"
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// reserveStock locks each product or variant, checks that enough stock is
+// left at the store after other active reservations and records a
+// reservation under reference. Composite products reserve their components
+// instead.
+func reserveStock(tx *sql.Tx, reference string, items []models.CheckoutItem, expiresAt time.Time, storeID int) ([]models.StockReservation, error) {
+	key, err := storeKey(tx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		return nil, errors.New("reservation reference is required")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("reservation must contain at least one item")
+	}
+
+	reservations := make([]models.StockReservation, 0, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity %d for product id %d", item.Quantity, item.ProductID)
+		}
+
+		unit, err := loadStockUnit(tx, item, "", true, key)
+		if err != nil {
+			return nil, err
+		}
+
+		draws := []models.CheckoutItem{{ProductID: unit.productID, VariantID: item.VariantID, Quantity: item.Quantity}}
+		if len(unit.components) > 0 {
+			draws = draws[:0]
+			for _, c := range unit.components {
+				if c.available < c.perUnit*item.Quantity {
+					return nil, fmt.Errorf("insufficient %s to reserve %s: requested %d, available %d", c.name, unit.label(), c.perUnit*item.Quantity, c.available)
+				}
+				draws = append(draws, models.CheckoutItem{ProductID: c.productID, Quantity: c.perUnit * item.Quantity})
+			}
+		} else if unit.available < item.Quantity {
+			return nil, fmt.Errorf("insufficient stock to reserve %s: requested %d, available %d", unit.label(), item.Quantity, unit.available)
+		}
+
+		for _, draw := range draws {
+			res := models.StockReservation{
+				ProductID: draw.ProductID,
+				VariantID: draw.VariantID,
+				StoreID:   key,
+				Quantity:  draw.Quantity,
+				Reference: reference,
+				ExpiresAt: expiresAt,
+			}
+			err = tx.QueryRow("INSERT INTO stock_reservations (product_id, variant_id, store_id, quantity, reference, expires_at) VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6) RETURNING id, created_at",
+				res.ProductID, res.VariantID, key, res.Quantity, res.Reference, res.ExpiresAt).Scan(&res.ID, &res.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+			reservations = append(reservations, res)
+		}
+	}
+
+	return reservations, nil
+}
+
+func releaseReservations(tx *sql.Tx, reference string) error {
+	if reference == "" {
+		return nil
+	}
+	_, err := tx.Exec("UPDATE stock_reservations SET released_at = NOW() WHERE reference = $1 AND released_at IS NULL", reference)
+	return err
+}
+
+// stockUnit is what a checkout line draws stock from: the product itself,
+// or one of its variants.
+type stockUnit struct {
+	productID  int
+	variantID  int
+	name       string
+	variant    string
+	price      int
+	cost       int
+	available  int
+	components []componentStock
+}
+
+// componentStock is a component a composite product draws on, with the
+// quantity one unit of the product uses.
+type componentStock struct {
+	productID int
+	name      string
+	perUnit   int
+	cost      int
+	available int
+}
+
+func (u stockUnit) label() string {
+	if u.variant == "" {
+		return u.name
+	}
+	return u.name + " (" + u.variant + ")"
+}
+
+// loadStockUnit reads the product or variant an item points at, with its
+// price and the stock left after active reservations other than those
+// under reservationRef, both at the store keyed by storeKey. When lock is
+// true the row stays locked until tx ends. A product with variants can
+// only be sold by variant; a composite product comes with the components
+// it draws stock from.
+func loadStockUnit(tx *sql.Tx, item models.CheckoutItem, reservationRef string, lock bool, storeKey int) (*stockUnit, error) {
+	unit := stockUnit{variantID: item.VariantID}
+
+	if item.VariantID != 0 {
+		query := `SELECT v.product_id, p.name, v.name, ` + storePriceExpr("v.price", "v.product_id", "v.id", "$3") + `, v.cost_price,
+			` + storeStockExpr("v.stock", "v.product_id", "v.id", "$3") + ` - ` + variantReservedExpr("v.id", "$2", "$3") + `
+			FROM product_variants v JOIN products p ON p.id = v.product_id
+			WHERE v.id = $1`
+		if lock {
+			query += " FOR UPDATE OF v"
+		}
+		err := tx.QueryRow(query, item.VariantID, reservationRef, storeKey).Scan(&unit.productID, &unit.name, &unit.variant, &unit.price, &unit.cost, &unit.available)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("variant id %d not found", item.VariantID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if item.ProductID != 0 && item.ProductID != unit.productID {
+			return nil, fmt.Errorf("variant id %d does not belong to product id %d", item.VariantID, item.ProductID)
+		}
+		return &unit, nil
+	}
+
+	query := `SELECT p.id, p.name, ` + storePriceExpr("p.price", "p.id", "0", "$3") + `, p.cost_price,
+		` + storeStockExpr("p.stock", "p.id", "0", "$3") + ` - ` + reservedExpr("p.id", "$2", "$3") + `,
+		EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+		FROM products p WHERE p.id = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	var hasVariants bool
+	err := tx.QueryRow(query, item.ProductID, reservationRef, storeKey).Scan(&unit.productID, &unit.name, &unit.price, &unit.cost, &unit.available, &hasVariants)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product id %d not found", item.ProductID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hasVariants {
+		return nil, fmt.Errorf("%s comes in variants, pick a variant_id", unit.name)
+	}
+
+	componentQuery := `SELECT c.id, c.name, pc.quantity, c.cost_price,
+		` + storeStockExpr("c.stock", "c.id", "0", "$3") + ` - ` + reservedExpr("c.id", "$2", "$3") + `
+		FROM product_components pc JOIN products c ON c.id = pc.component_id
+		WHERE pc.product_id = $1 ORDER BY c.id`
+	if lock {
+		componentQuery += " FOR UPDATE OF c"
+	}
+	rows, err := tx.Query(componentQuery, unit.productID, reservationRef, storeKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c componentStock
+		if err := rows.Scan(&c.productID, &c.name, &c.perUnit, &c.cost, &c.available); err != nil {
+			return nil, err
+		}
+		unit.components = append(unit.components, c)
+	}
+	if len(unit.components) > 0 {
+		unit.cost = 0
+		for _, c := range unit.components {
+			unit.cost += c.cost * c.perUnit
+		}
+	}
+
+	return &unit, rows.Err()
+}
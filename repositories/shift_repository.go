@@ -0,0 +1,320 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ShiftRepository struct {
+	db  *sql.DB
+	day models.BusinessDay
+}
+
+func NewShiftRepository(db *sql.DB, day models.BusinessDay) *ShiftRepository {
+	return &ShiftRepository{db: db, day: day}
+}
+
+// BusinessDay is the business day shifts are counted towards.
+func (r *ShiftRepository) BusinessDay() models.BusinessDay {
+	return r.day
+}
+
+const shiftSelect = `SELECT s.id, s.cashier_id, COALESCE(u.name, ''), COALESCE(s.register_id, ''), s.status, s.opening_cash,
+	s.expected_cash, s.counted_cash, s.variance, s.note, s.opened_at, s.closed_at
+	FROM shifts s
+	LEFT JOIN users u ON u.id = s.cashier_id`
+
+// scanShift reads a shift and dates it with the business day it was opened
+// on.
+func (r *ShiftRepository) scanShift(row rowScanner, s *models.Shift) error {
+	err := row.Scan(&s.ID, &s.CashierID, &s.CashierName, &s.RegisterID, &s.Status, &s.OpeningCash,
+		&s.ExpectedCash, &s.CountedCash, &s.Variance, &s.Note, &s.OpenedAt, &s.ClosedAt)
+	if err != nil {
+		return err
+	}
+	s.BusinessDate = r.day.Date(s.OpenedAt).Format("2006-01-02")
+	return nil
+}
+
+// GetAll lists shifts, optionally only those opened on the business day
+// of date.
+func (r *ShiftRepository) GetAll(status string, date *time.Time) ([]models.Shift, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("s.status = $%d", len(args)))
+	}
+	if date != nil {
+		args = append(args, r.day.Start(*date), r.day.Start(date.AddDate(0, 0, 1)))
+		conditions = append(conditions, fmt.Sprintf("s.opened_at >= $%d AND s.opened_at < $%d", len(args)-1, len(args)))
+	}
+
+	query := shiftSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY s.opened_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := make([]models.Shift, 0)
+	for rows.Next() {
+		var shift models.Shift
+		if err := r.scanShift(rows, &shift); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+
+	return shifts, rows.Err()
+}
+
+func (r *ShiftRepository) GetByID(id int) (*models.Shift, error) {
+	var shift models.Shift
+	err := r.scanShift(r.db.QueryRow(shiftSelect+" WHERE s.id = $1", id), &shift)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("shift not found")
+		}
+		return nil, err
+	}
+	return &shift, nil
+}
+
+func (r *ShiftRepository) GetOpenByCashier(cashierID int) (*models.Shift, error) {
+	var shift models.Shift
+	err := r.scanShift(r.db.QueryRow(shiftSelect+" WHERE s.cashier_id = $1 AND s.status = $2", cashierID, models.ShiftStatusOpen), &shift)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("no open shift")
+		}
+		return nil, err
+	}
+	return &shift, nil
+}
+
+func (r *ShiftRepository) Open(shift *models.Shift, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM shifts WHERE cashier_id = $1 AND status = $2)", shift.CashierID, models.ShiftStatusOpen).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("cashier already has an open shift")
+	}
+
+	query := "INSERT INTO shifts (cashier_id, register_id, status, opening_cash, note) VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING id, opened_at"
+	err = tx.QueryRow(query, shift.CashierID, shift.RegisterID, models.ShiftStatusOpen, shift.OpeningCash, shift.Note).Scan(&shift.ID, &shift.OpenedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		// Another open for the same cashier won the race.
+		return errors.New("cashier already has an open shift")
+	}
+	if err != nil {
+		return err
+	}
+	shift.Status = models.ShiftStatusOpen
+	shift.BusinessDate = r.day.Date(shift.OpenedAt).Format("2006-01-02")
+
+	if err := writeAudit(tx, actor, AuditCreate, "shift", shift.ID, nil, shift); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ShiftRepository) AddCashMovement(m *models.CashMovement, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(tx, m.ShiftID, "FOR SHARE"); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO shift_cash_movements (shift_id, type, amount, reason, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+	err = tx.QueryRow(query, m.ShiftID, m.Type, m.Amount, m.Reason, m.CreatedBy).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "shift_cash_movement", m.ID, nil, m); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close locks the shift so no checkout can join it, works out the cash
+// that should be in the drawer and stores it with the counted amount.
+func (r *ShiftRepository) Close(id int, req models.CloseShiftRequest, actor models.Actor) (*models.ZReport, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(tx, id, "FOR UPDATE"); err != nil {
+		return nil, err
+	}
+
+	var shift models.Shift
+	if err := r.scanShift(tx.QueryRow(shiftSelect+" WHERE s.id = $1", id), &shift); err != nil {
+		return nil, err
+	}
+	report, err := summarizeShift(tx, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	variance := req.CountedCash - report.ExpectedCash
+	query := `UPDATE shifts SET status = $1, expected_cash = $2, counted_cash = $3, variance = $4,
+		note = CASE WHEN $5 = '' THEN note ELSE $5 END, closed_at = NOW()
+		WHERE id = $6 RETURNING closed_at`
+	if _, err := tx.Exec(query, models.ShiftStatusClosed, report.ExpectedCash, req.CountedCash, variance, req.Note, id); err != nil {
+		return nil, err
+	}
+
+	var closed models.Shift
+	if err := r.scanShift(tx.QueryRow(shiftSelect+" WHERE s.id = $1", id), &closed); err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "shift", id, shift, closed); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	report.Shift = closed
+	report.CountedCash = report.Shift.CountedCash
+	report.Variance = report.Shift.Variance
+	return report, nil
+}
+
+func (r *ShiftRepository) Summary(id int) (*models.ZReport, error) {
+	shift, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	report, err := summarizeShift(r.db, *shift)
+	if err != nil {
+		return nil, err
+	}
+	report.CountedCash = shift.CountedCash
+	report.Variance = shift.Variance
+	return report, nil
+}
+
+// summarizeShift totals the sales, tenders and cash movements of a shift.
+// Sales recorded without payments are counted as cash.
+func summarizeShift(q querier, shift models.Shift) (*models.ZReport, error) {
+	report := &models.ZReport{Shift: shift}
+
+	err := q.QueryRow("SELECT COUNT(*), COALESCE(SUM(total_amount), 0), COALESCE(SUM(change_amount), 0) FROM transactions WHERE shift_id = $1", shift.ID).
+		Scan(&report.TransactionCount, &report.TotalSales, &report.ChangeGiven)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`SELECT method, SUM(amount), COUNT(*) FROM (
+			SELECT tp.method, tp.amount FROM transaction_payments tp
+			JOIN transactions t ON t.id = tp.transaction_id
+			WHERE t.shift_id = $1
+			UNION ALL
+			SELECT $2, t.total_amount FROM transactions t
+			WHERE t.shift_id = $1 AND NOT EXISTS (SELECT 1 FROM transaction_payments tp WHERE tp.transaction_id = t.id)
+		) p
+		GROUP BY method
+		ORDER BY method`, shift.ID, models.PaymentMethodCash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report.Payments = make([]models.PaymentSummary, 0)
+	for rows.Next() {
+		var p models.PaymentSummary
+		if err := rows.Scan(&p.Method, &p.Amount, &p.Count); err != nil {
+			return nil, err
+		}
+		if p.Method == models.PaymentMethodCash {
+			report.CashSales = p.Amount
+		}
+		report.Payments = append(report.Payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	movementRows, err := q.Query("SELECT id, shift_id, type, amount, reason, created_by, created_at FROM shift_cash_movements WHERE shift_id = $1 ORDER BY created_at", shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer movementRows.Close()
+
+	report.CashMovements = make([]models.CashMovement, 0)
+	for movementRows.Next() {
+		var m models.CashMovement
+		if err := movementRows.Scan(&m.ID, &m.ShiftID, &m.Type, &m.Amount, &m.Reason, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if m.Type == models.CashMovementIn {
+			report.CashIn += m.Amount
+		} else {
+			report.CashOut += m.Amount
+		}
+		report.CashMovements = append(report.CashMovements, m)
+	}
+	if err := movementRows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.ExpectedCash = shift.OpeningCash + report.CashSales - report.ChangeGiven + report.CashIn - report.CashOut
+	return report, nil
+}
+
+func lockOpenShift(tx *sql.Tx, id int, lock string) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM shifts WHERE id = $1 "+lock, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errors.New("shift not found")
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.ShiftStatusOpen {
+		return fmt.Errorf("shift %d is already closed", id)
+	}
+	return nil
+}
+
+// openShiftFor returns the open shift cash taken or paid out by cashierID
+// belongs to, holding a share lock so the shift cannot close meanwhile.
+func openShiftFor(tx *sql.Tx, cashierID int) (int, error) {
+	var shiftID int
+	err := tx.QueryRow("SELECT id FROM shifts WHERE cashier_id = $1 AND status = $2 FOR SHARE", cashierID, models.ShiftStatusOpen).Scan(&shiftID)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("no open shift, open a shift before handling cash")
+	}
+	return shiftID, err
+}
@@ -0,0 +1,320 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"math"
+	"time"
+)
+
+const (
+	CostingFIFO    = "fifo"
+	CostingAverage = "average"
+)
+
+// StockLedger is the one place stock quantities move. Every change is
+// written to stock_movements with the cost value it moved at, incoming stock
+// becomes a cost layer, and outgoing stock is taken from the oldest open
+// layers first. Under average costing every receipt folds the open layers
+// into one at the weighted average cost, so sales are costed at the moving
+// average instead. Cost layers are shared by all stores; each movement
+// records the store whose stock it moved.
+type StockLedger struct {
+	method string
+}
+
+func NewStockLedger(method string) (*StockLedger, error) {
+	if method == "" {
+		method = CostingFIFO
+	}
+	switch method {
+	case CostingFIFO, CostingAverage:
+	default:
+		return nil, fmt.Errorf("invalid inventory costing method %q, use fifo or average", method)
+	}
+
+	return &StockLedger{method: method}, nil
+}
+
+func (l *StockLedger) Method() string {
+	return l.method
+}
+
+// stockReference says which document moved the stock, and at which store:
+// storeID is a store key as returned by storeKey.
+type stockReference struct {
+	movementType string
+	entityType   string
+	entityID     int
+	storeID      int
+}
+
+// receive books qty units in at unitCost each.
+func (l *StockLedger) receive(tx *sql.Tx, productID, variantID, qty, unitCost int, ref stockReference) error {
+	if err := openLedger(tx, productID, variantID); err != nil {
+		return err
+	}
+
+	var onHand, onHandValue int
+	if l.method == CostingAverage {
+		err := tx.QueryRow(`SELECT COALESCE(SUM(remaining), 0), COALESCE(SUM(remaining * unit_cost), 0) FROM cost_layers
+			WHERE product_id = $1 AND COALESCE(variant_id, 0) = $2 AND remaining > 0`, productID, variantID).Scan(&onHand, &onHandValue)
+		if err != nil {
+			return err
+		}
+		if onHand > 0 {
+			_, err = tx.Exec("UPDATE cost_layers SET remaining = 0 WHERE product_id = $1 AND COALESCE(variant_id, 0) = $2 AND remaining > 0", productID, variantID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	quantity, layerCost, diff := blendLayer(onHand, onHandValue, qty, unitCost)
+	var layerID int
+	err := tx.QueryRow("INSERT INTO cost_layers (product_id, variant_id, quantity, remaining, unit_cost) VALUES ($1, NULLIF($2, 0), $3, $3, $4) RETURNING id",
+		productID, variantID, quantity, layerCost).Scan(&layerID)
+	if err != nil {
+		return err
+	}
+
+	if err := postMovement(tx, productID, variantID, layerID, qty, qty*unitCost, ref); err != nil {
+		return err
+	}
+	if diff != 0 {
+		reval := stockReference{models.StockMovementRevaluation, ref.entityType, ref.entityID, ref.storeID}
+		if err := postMovement(tx, productID, variantID, layerID, 0, diff, reval); err != nil {
+			return err
+		}
+	}
+
+	return moveStock(tx, productID, variantID, qty, ref.storeID)
+}
+
+// consume books qty units out of the oldest open layers and returns their
+// cost. Stock beyond the layers, such as stock that was never received
+// through the ledger, is costed at the product's cost price.
+func (l *StockLedger) consume(tx *sql.Tx, productID, variantID, qty int, ref stockReference) (int, error) {
+	if err := openLedger(tx, productID, variantID); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT id, remaining, unit_cost FROM cost_layers
+		WHERE product_id = $1 AND COALESCE(variant_id, 0) = $2 AND remaining > 0
+		ORDER BY id FOR UPDATE`, productID, variantID)
+	if err != nil {
+		return 0, err
+	}
+	var layers []costLayer
+	for rows.Next() {
+		var c costLayer
+		if err := rows.Scan(&c.id, &c.remaining, &c.unitCost); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		layers = append(layers, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	draws, left := drawLayers(layers, qty)
+	cost := 0
+	for _, d := range draws {
+		_, err = tx.Exec("UPDATE cost_layers SET remaining = remaining - $1 WHERE id = $2", d.remaining, d.id)
+		if err != nil {
+			return 0, err
+		}
+		if err := postMovement(tx, productID, variantID, d.id, -d.remaining, -d.remaining*d.unitCost, ref); err != nil {
+			return 0, err
+		}
+		cost += d.remaining * d.unitCost
+	}
+	if left > 0 {
+		table, id := stockTable(productID, variantID)
+		var costPrice int
+		err := tx.QueryRow(fmt.Sprintf("SELECT cost_price FROM %s WHERE id = $1", table), id).Scan(&costPrice)
+		if err != nil {
+			return 0, err
+		}
+		if err := postMovement(tx, productID, variantID, 0, -left, -left*costPrice, ref); err != nil {
+			return 0, err
+		}
+		cost += left * costPrice
+	}
+
+	return cost, moveStock(tx, productID, variantID, -qty, ref.storeID)
+}
+
+// adjust moves stock by delta, costing additions at the current cost price,
+// and returns the value moved. For lot-tracked products a write-off comes
+// out of the earliest expiring lots, expired ones included, and additions
+// go into the lot without a number.
+func (l *StockLedger) adjust(tx *sql.Tx, productID, variantID, delta int, ref stockReference) (int, error) {
+	if delta == 0 {
+		return 0, nil
+	}
+	tracked, err := lotTracked(tx, productID)
+	if err != nil {
+		return 0, err
+	}
+
+	if delta < 0 {
+		cost, err := l.consume(tx, productID, variantID, -delta, ref)
+		if err != nil {
+			return 0, err
+		}
+		if tracked {
+			if _, err := takeLots(tx, productID, variantID, ref.storeID, -delta, time.Time{}); err != nil {
+				return 0, err
+			}
+		}
+		return -cost, nil
+	}
+
+	table, id := stockTable(productID, variantID)
+	var costPrice int
+	err = tx.QueryRow(fmt.Sprintf("SELECT cost_price FROM %s WHERE id = $1", table), id).Scan(&costPrice)
+	if err != nil {
+		return 0, err
+	}
+	if err := l.receive(tx, productID, variantID, delta, costPrice, ref); err != nil {
+		return 0, err
+	}
+	if tracked {
+		if err := putLot(tx, productID, variantID, ref.storeID, "", "", delta); err != nil {
+			return 0, err
+		}
+	}
+	return delta * costPrice, nil
+}
+
+// reverse puts back everything a document took out, at the cost it went
+// out at, e.g. the stock of a refunded sale. It reports how many movements
+// it reversed. Stock goes back to the store it came out of.
+func (l *StockLedger) reverse(tx *sql.Tx, from stockReference, ref stockReference) (int, error) {
+	rows, err := tx.Query(`SELECT product_id, COALESCE(variant_id, 0), COALESCE(store_id, 0), quantity, value FROM stock_movements
+		WHERE movement_type = $1 AND reference_type = $2 AND reference_id = $3 AND quantity < 0
+		ORDER BY id`, from.movementType, from.entityType, from.entityID)
+	if err != nil {
+		return 0, err
+	}
+	type out struct{ productID, variantID, storeID, quantity, value int }
+	var outs []out
+	for rows.Next() {
+		var o out
+		if err := rows.Scan(&o.productID, &o.variantID, &o.storeID, &o.quantity, &o.value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		outs = append(outs, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, o := range outs {
+		ref.storeID = o.storeID
+		if err := l.receive(tx, o.productID, o.variantID, -o.quantity, o.value/o.quantity, ref); err != nil {
+			return 0, err
+		}
+	}
+	return len(outs), nil
+}
+
+// costLayer is an open cost layer: remaining units at unitCost each.
+type costLayer struct{ id, remaining, unitCost int }
+
+// blendLayer works out the layer a receipt of qty units at unitCost opens
+// when onHand units worth onHandValue are folded into it, as average
+// costing does; FIFO folds nothing in. The layer cost is rounded to whole
+// rupiah, and diff is the value the rounding adds, to be posted so the
+// ledger value matches the layer.
+func blendLayer(onHand, onHandValue, qty, unitCost int) (quantity, layerCost, diff int) {
+	quantity, value := qty+onHand, qty*unitCost+onHandValue
+	layerCost = unitCost
+	if quantity > 0 {
+		layerCost = int(math.Round(float64(value) / float64(quantity)))
+	}
+	return quantity, layerCost, quantity*layerCost - value
+}
+
+// drawLayers takes qty units out of layers, oldest first. Each draw is the
+// layer with remaining set to the units taken from it; left is what the
+// layers could not cover.
+func drawLayers(layers []costLayer, qty int) (draws []costLayer, left int) {
+	left = qty
+	for _, c := range layers {
+		if left == 0 {
+			break
+		}
+		c.remaining = min(left, c.remaining)
+		draws = append(draws, c)
+		left -= c.remaining
+	}
+	return draws, left
+}
+
+func stockTable(productID, variantID int) (string, int) {
+	if variantID != 0 {
+		return "product_variants", variantID
+	}
+	return "products", productID
+}
+
+// openLedger locks the stock row and, the first time a product or variant
+// moves, books the stock it already had as an opening layer at its cost
+// price, so stock from before the ledger is valued too. That stock is the
+// default store's.
+func openLedger(tx *sql.Tx, productID, variantID int) error {
+	table, id := stockTable(productID, variantID)
+	var stock, costPrice int
+	err := tx.QueryRow(fmt.Sprintf("SELECT stock, cost_price FROM %s WHERE id = $1 FOR UPDATE", table), id).Scan(&stock, &costPrice)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product id %d not found", productID)
+	}
+	if err != nil {
+		return err
+	}
+	if stock <= 0 {
+		return nil
+	}
+
+	var started bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM stock_movements WHERE product_id = $1 AND COALESCE(variant_id, 0) = $2)", productID, variantID).Scan(&started)
+	if err != nil || started {
+		return err
+	}
+
+	var layerID int
+	err = tx.QueryRow("INSERT INTO cost_layers (product_id, variant_id, quantity, remaining, unit_cost) VALUES ($1, NULLIF($2, 0), $3, $3, $4) RETURNING id",
+		productID, variantID, stock, costPrice).Scan(&layerID)
+	if err != nil {
+		return err
+	}
+	return postMovement(tx, productID, variantID, layerID, stock, stock*costPrice, stockReference{movementType: models.StockMovementOpening})
+}
+
+func postMovement(tx *sql.Tx, productID, variantID, layerID, quantity, value int, ref stockReference) error {
+	_, err := tx.Exec(`INSERT INTO stock_movements (product_id, variant_id, layer_id, movement_type, quantity, value, reference_type, reference_id, store_id)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, NULLIF($7, ''), NULLIF($8, 0), NULLIF($9, 0))`,
+		productID, variantID, layerID, ref.movementType, quantity, value, ref.entityType, ref.entityID, ref.storeID)
+	return err
+}
+
+// moveStock changes the stock of a product or variant at a store. The
+// product row keeps the total of all stores, see storeStockExpr.
+func moveStock(tx *sql.Tx, productID, variantID, delta, storeID int) error {
+	table, id := stockTable(productID, variantID)
+	_, err := tx.Exec(fmt.Sprintf("UPDATE %s SET stock = stock + $1, updated_at = NOW() WHERE id = $2", table), delta, id)
+	if err != nil || storeID == 0 {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO store_stock (store_id, product_id, variant_id, stock) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (store_id, product_id, variant_id) DO UPDATE SET stock = store_stock.stock + EXCLUDED.stock`,
+		storeID, productID, variantID, delta)
+	return err
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlendLayer(t *testing.T) {
+	tests := []struct {
+		name                      string
+		onHand, onHandValue       int
+		qty, unitCost             int
+		quantity, layerCost, diff int
+	}{
+		{"fifo receipt", 0, 0, 10, 1500, 10, 1500, 0},
+		{"nothing on hand", 0, 0, 4, 2500, 4, 2500, 0},
+		{"average of equal lots", 10, 10000, 10, 2000, 20, 1500, 0},
+		{"weighted by quantity", 30, 30000, 10, 2000, 40, 1250, 0},
+		{"rounds down", 2, 2000, 1, 1001, 3, 1000, -1},
+		{"rounds up", 1, 1000, 2, 1001, 3, 1001, 1},
+		{"half rounds away from zero", 1, 1000, 1, 1001, 2, 1001, 1},
+		{"zero quantity keeps the unit cost", 0, 0, 0, 1200, 0, 1200, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quantity, layerCost, diff := blendLayer(tt.onHand, tt.onHandValue, tt.qty, tt.unitCost)
+			if quantity != tt.quantity || layerCost != tt.layerCost || diff != tt.diff {
+				t.Errorf("blendLayer(%d, %d, %d, %d) = %d, %d, %d, want %d, %d, %d",
+					tt.onHand, tt.onHandValue, tt.qty, tt.unitCost, quantity, layerCost, diff, tt.quantity, tt.layerCost, tt.diff)
+			}
+			if value := tt.qty*tt.unitCost + tt.onHandValue; quantity*layerCost-diff != value {
+				t.Errorf("layer value %d less diff %d does not match booked value %d", quantity*layerCost, diff, value)
+			}
+		})
+	}
+}
+
+func TestDrawLayers(t *testing.T) {
+	layers := []costLayer{
+		{id: 1, remaining: 5, unitCost: 1000},
+		{id: 2, remaining: 3, unitCost: 1200},
+		{id: 3, remaining: 10, unitCost: 1100},
+	}
+	tests := []struct {
+		name  string
+		qty   int
+		draws []costLayer
+		left  int
+	}{
+		{"within the oldest layer", 4, []costLayer{{1, 4, 1000}}, 0},
+		{"empties the oldest layer", 5, []costLayer{{1, 5, 1000}}, 0},
+		{"spans layers oldest first", 7, []costLayer{{1, 5, 1000}, {2, 2, 1200}}, 0},
+		{"takes every layer", 18, []costLayer{{1, 5, 1000}, {2, 3, 1200}, {3, 10, 1100}}, 0},
+		{"more than the layers hold", 20, []costLayer{{1, 5, 1000}, {2, 3, 1200}, {3, 10, 1100}}, 2},
+		{"nothing", 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draws, left := drawLayers(layers, tt.qty)
+			if !reflect.DeepEqual(draws, tt.draws) || left != tt.left {
+				t.Errorf("drawLayers(%d) = %v, %d, want %v, %d", tt.qty, draws, left, tt.draws, tt.left)
+			}
+		})
+	}
+
+	if _, left := drawLayers(nil, 3); left != 3 {
+		t.Errorf("drawLayers with no layers left %d, want 3", left)
+	}
+	if layers[0].remaining != 5 {
+		t.Errorf("drawLayers changed the layers it drew from")
+	}
+}
@@ -0,0 +1,353 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type StocktakeRepository struct {
+	db     *sql.DB
+	ledger *StockLedger
+}
+
+func NewStocktakeRepository(db *sql.DB, ledger *StockLedger) *StocktakeRepository {
+	return &StocktakeRepository{db: db, ledger: ledger}
+}
+
+const stocktakeSelect = `SELECT s.id, s.status, s.note, COALESCE(s.category_id, 0), COALESCE(s.store_id, 0), COALESCE(s.created_by, 0), s.created_at,
+	COALESCE(s.closed_by, 0), s.closed_at,
+	(SELECT COUNT(*) FROM stocktake_lines l WHERE l.stocktake_id = s.id),
+	(SELECT COUNT(*) FROM stocktake_lines l WHERE l.stocktake_id = s.id AND l.counted IS NOT NULL)
+	FROM stocktakes s`
+
+func scanStocktake(row rowScanner, s *models.Stocktake) error {
+	return row.Scan(&s.ID, &s.Status, &s.Note, &s.CategoryID, &s.StoreID, &s.CreatedBy, &s.CreatedAt, &s.ClosedBy, &s.ClosedAt, &s.LineCount, &s.CountedLines)
+}
+
+func (r *StocktakeRepository) GetAll(status string) ([]models.Stocktake, error) {
+	query := stocktakeSelect
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE s.status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY s.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := make([]models.Stocktake, 0)
+	for rows.Next() {
+		var s models.Stocktake
+		if err := scanStocktake(rows, &s); err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, s)
+	}
+
+	return stocktakes, rows.Err()
+}
+
+func (r *StocktakeRepository) GetByID(id int) (*models.Stocktake, error) {
+	return getStocktake(r.db, id, true)
+}
+
+// Create starts a stocktake at the store the actor works in and snapshots
+// the stock there of every product and variant, or of one category, as the
+// expected quantities until they are counted.
+func (r *StocktakeRepository) Create(stocktake *models.Stocktake, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	key, err := storeKey(tx, actor.StoreID)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO stocktakes (status, note, category_id, store_id, created_by) VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0)) RETURNING id"
+	err = tx.QueryRow(query, models.StocktakeStatusOpen, stocktake.Note, stocktake.CategoryID, key, actor.UserID).Scan(&stocktake.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO stocktake_lines (stocktake_id, product_id, variant_id, expected)
+		SELECT $1, p.id, NULL, `+storeStockExpr("p.stock", "p.id", "0", "$3")+` FROM products p
+		WHERE ($2 = 0 OR p.category_id = $2)
+			AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+			AND NOT EXISTS (SELECT 1 FROM product_components c WHERE c.product_id = p.id)
+		UNION ALL
+		SELECT $1, v.product_id, v.id, `+storeStockExpr("v.stock", "v.product_id", "v.id", "$3")+` FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		WHERE ($2 = 0 OR p.category_id = $2)`, stocktake.ID, stocktake.CategoryID, key)
+	if err != nil {
+		return err
+	}
+
+	created, err := getStocktake(tx, stocktake.ID, false)
+	if err != nil {
+		return err
+	}
+	*stocktake = *created
+
+	if err := writeAudit(tx, actor, AuditCreate, "stocktake", stocktake.ID, nil, stocktake); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Count records a batch of counted quantities on an open stocktake. A
+// line's expected quantity is the stock when it is first counted, or
+// counted again in set mode; later batches adding to a count keep it.
+func (r *StocktakeRepository) Count(id int, req models.StocktakeCountRequest, actor models.Actor) (*models.Stocktake, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stocktake, err := lockOpenStocktake(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range req.Items {
+		if item.SKU != "" {
+			err := tx.QueryRow("SELECT product_id, id FROM product_variants WHERE sku = $1", item.SKU).Scan(&item.ProductID, &item.VariantID)
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("no variant with sku %q", item.SKU)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// What is on the shelf now is compared with the stock now, so sales
+		// and receipts since the stocktake started are not counted twice.
+		expected, err := storeStock(tx, item.ProductID, item.VariantID, stocktake.StoreID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("product id %d variant id %d is not part of this stocktake", item.ProductID, item.VariantID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		counted, snapshot := "$1", "$5"
+		if req.Mode == models.StocktakeCountAdd {
+			counted = "COALESCE(counted, 0) + $1"
+			snapshot = "CASE WHEN counted IS NULL THEN $5 ELSE expected END"
+		}
+		result, err := tx.Exec(`UPDATE stocktake_lines SET counted = `+counted+`, expected = `+snapshot+`, counted_at = NOW()
+			WHERE stocktake_id = $2 AND product_id = $3 AND COALESCE(variant_id, 0) = $4`,
+			item.Quantity, id, item.ProductID, item.VariantID, expected)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("product id %d variant id %d is not part of this stocktake", item.ProductID, item.VariantID)
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "stocktake", id, nil, req); err != nil {
+		return nil, err
+	}
+
+	stocktake, err = getStocktake(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return stocktake, tx.Commit()
+}
+
+// Approve posts the variance of every counted line as a stock adjustment
+// and closes the stocktake. Lines never counted are left alone. The
+// variance is against the stock when the line was counted and is added to
+// the current stock rather than overwriting it, so sales rung up since the
+// count still count; stock never goes below zero.
+func (r *StocktakeRepository) Approve(id int, actor models.Actor) (*models.Stocktake, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenStocktake(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT id, product_id, COALESCE(variant_id, 0), counted - expected FROM stocktake_lines
+		WHERE stocktake_id = $1 AND counted IS NOT NULL AND counted <> expected
+		ORDER BY product_id, variant_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	type variance struct{ lineID, productID, variantID, quantity int }
+	var variances []variance
+	for rows.Next() {
+		var v variance
+		if err := rows.Scan(&v.lineID, &v.productID, &v.variantID, &v.quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		variances = append(variances, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ref := stockReference{models.StockMovementStocktake, "stocktake", id, before.StoreID}
+	for _, v := range variances {
+		table, stockID := stockTable(v.productID, v.variantID)
+		_, err := tx.Exec(fmt.Sprintf("SELECT 1 FROM %s WHERE id = $1 FOR UPDATE", table), stockID)
+		if err != nil {
+			return nil, err
+		}
+		stock, err := storeStock(tx, v.productID, v.variantID, before.StoreID)
+		if err == sql.ErrNoRows {
+			// Deleted since the count started.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := r.ledger.adjust(tx, v.productID, v.variantID, max(v.quantity, -stock), ref)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("UPDATE stocktake_lines SET variance_value = $1 WHERE id = $2", value, v.lineID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := closeStocktake(tx, id, models.StocktakeStatusApproved, actor); err != nil {
+		return nil, err
+	}
+	after, err := getStocktake(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "stocktake", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+func (r *StocktakeRepository) Cancel(id int, actor models.Actor) (*models.Stocktake, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOpenStocktake(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := closeStocktake(tx, id, models.StocktakeStatusCancelled, actor); err != nil {
+		return nil, err
+	}
+	after, err := getStocktake(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "stocktake", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+func lockOpenStocktake(tx *sql.Tx, id int) (*models.Stocktake, error) {
+	var stocktake models.Stocktake
+	err := scanStocktake(tx.QueryRow(stocktakeSelect+" WHERE s.id = $1 FOR UPDATE OF s", id), &stocktake)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("stocktake not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stocktake.Status != models.StocktakeStatusOpen {
+		return nil, fmt.Errorf("stocktake %d is already %s", id, stocktake.Status)
+	}
+	return &stocktake, nil
+}
+
+func closeStocktake(tx *sql.Tx, id int, status string, actor models.Actor) error {
+	_, err := tx.Exec("UPDATE stocktakes SET status = $1, closed_by = NULLIF($2, 0), closed_at = NOW() WHERE id = $3", status, actor.UserID, id)
+	return err
+}
+
+// getStocktake reads a stocktake with its lines. Open stocktakes value
+// their variances at the current cost price; closed ones show what was
+// posted.
+func getStocktake(q querier, id int, withLines bool) (*models.Stocktake, error) {
+	var stocktake models.Stocktake
+	err := scanStocktake(q.QueryRow(stocktakeSelect+" WHERE s.id = $1", id), &stocktake)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("stocktake not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`SELECT l.product_id, COALESCE(l.variant_id, 0), COALESCE(p.name, ''), COALESCE(v.name, ''), COALESCE(v.sku, ''),
+			l.expected, l.counted, l.counted_at,
+			COALESCE(l.variance_value, (l.counted - l.expected) * COALESCE(v.cost_price, p.cost_price), 0)
+		FROM stocktake_lines l
+		LEFT JOIN products p ON p.id = l.product_id
+		LEFT JOIN product_variants v ON v.id = l.variant_id
+		WHERE l.stocktake_id = $1
+		ORDER BY p.name, v.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]models.StocktakeLine, 0)
+	for rows.Next() {
+		var line models.StocktakeLine
+		var variant string
+		err := rows.Scan(&line.ProductID, &line.VariantID, &line.Name, &variant, &line.SKU, &line.Expected, &line.Counted, &line.CountedAt, &line.VarianceValue)
+		if err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			line.Name += " (" + variant + ")"
+		}
+		if line.Counted != nil {
+			line.Variance = *line.Counted - line.Expected
+		}
+		stocktake.VarianceValue += line.VarianceValue
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if withLines {
+		stocktake.Lines = lines
+	}
+
+	return &stocktake, nil
+}
@@ -0,0 +1,293 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type StoreRepository struct {
+	db *sql.DB
+}
+
+func NewStoreRepository(db *sql.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+const storeSelect = "SELECT id, code, name, address, phone, is_default FROM stores"
+
+func scanStore(row rowScanner, s *models.Store) error {
+	return row.Scan(&s.ID, &s.Code, &s.Name, &s.Address, &s.Phone, &s.IsDefault)
+}
+
+func (r *StoreRepository) GetAll() ([]models.Store, error) {
+	rows, err := r.db.Query(storeSelect + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := make([]models.Store, 0)
+	for rows.Next() {
+		var store models.Store
+		if err := scanStore(rows, &store); err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	return stores, rows.Err()
+}
+
+func (r *StoreRepository) GetByID(id int) (*models.Store, error) {
+	var store models.Store
+	err := scanStore(r.db.QueryRow(storeSelect+" WHERE id = $1", id), &store)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("store not found")
+		}
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// Create adds a store. The first store becomes the default store.
+func (r *StoreRepository) Create(store *models.Store, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE stores IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	query := `INSERT INTO stores (code, name, address, phone, is_default)
+		VALUES ($1, $2, $3, $4, NOT EXISTS (SELECT 1 FROM stores)) RETURNING id, is_default`
+	err = tx.QueryRow(query, store.Code, store.Name, store.Address, store.Phone).Scan(&store.ID, &store.IsDefault)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "store", store.ID, nil, store); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *StoreRepository) Update(store *models.Store, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockStore(tx, store.ID)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE stores SET code = $1, name = $2, address = $3, phone = $4, updated_at = NOW() WHERE id = $5"
+	_, err = tx.Exec(query, store.Code, store.Name, store.Address, store.Phone, store.ID)
+	if err != nil {
+		return err
+	}
+	store.IsDefault = before.IsDefault
+
+	if err := writeAudit(tx, actor, AuditUpdate, "store", store.ID, before, store); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a store that has never held stock, sold anything or
+// taken part in a transfer. The default store cannot be deleted.
+func (r *StoreRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockStore(tx, id)
+	if err != nil {
+		return err
+	}
+	if before.IsDefault {
+		return fmt.Errorf("%s is the default store and cannot be deleted", before.Name)
+	}
+
+	var used bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM stock_movements WHERE store_id = $1)
+		OR EXISTS (SELECT 1 FROM transactions WHERE store_id = $1)
+		OR EXISTS (SELECT 1 FROM stock_transfers WHERE from_store_id = $1 OR to_store_id = $1)
+		OR EXISTS (SELECT 1 FROM users WHERE store_id = $1)`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("store %s has stock, sales, transfers or staff and cannot be deleted", before.Name)
+	}
+
+	if _, err := tx.Exec("DELETE FROM store_stock WHERE store_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stores WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "store", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lockStore(tx *sql.Tx, id int) (*models.Store, error) {
+	var store models.Store
+	err := scanStore(tx.QueryRow(storeSelect+" WHERE id = $1 FOR UPDATE", id), &store)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("store not found")
+		}
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// GetProducts lists every product and variant with its stock and selling
+// price at a store.
+func (r *StoreRepository) GetProducts(storeID int) ([]models.StoreProduct, error) {
+	key, err := storeKey(r.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT p.id, 0, p.name, `+storeStockExpr("p.stock", "p.id", "0", "$1")+`, p.price,
+			(SELECT ss.price FROM store_stock ss WHERE ss.store_id = $1 AND ss.product_id = p.id AND ss.variant_id = 0)
+		FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+		UNION ALL
+		SELECT v.product_id, v.id, p.name || ' (' || v.name || ')', `+storeStockExpr("v.stock", "v.product_id", "v.id", "$1")+`, v.price,
+			(SELECT ss.price FROM store_stock ss WHERE ss.store_id = $1 AND ss.product_id = v.product_id AND ss.variant_id = v.id)
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		ORDER BY 3`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]models.StoreProduct, 0)
+	for rows.Next() {
+		sp := models.StoreProduct{StoreID: storeID}
+		if err := rows.Scan(&sp.ProductID, &sp.VariantID, &sp.Name, &sp.Stock, &sp.Price, &sp.PriceOverride); err != nil {
+			return nil, err
+		}
+		if sp.PriceOverride != nil {
+			sp.Price = *sp.PriceOverride
+		}
+		products = append(products, sp)
+	}
+
+	return products, rows.Err()
+}
+
+// SetPrices sets or clears a store's price overrides. The default store
+// sells at the products' own prices.
+func (r *StoreRepository) SetPrices(storeID int, prices []models.StorePrice, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	store, err := lockStore(tx, storeID)
+	if err != nil {
+		return err
+	}
+	if store.IsDefault {
+		return fmt.Errorf("%s is the default store, change the product prices instead", store.Name)
+	}
+
+	for _, price := range prices {
+		unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: price.ProductID, VariantID: price.VariantID}, "", false, 0)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO store_stock (store_id, product_id, variant_id, stock, price) VALUES ($1, $2, $3, 0, $4)
+			ON CONFLICT (store_id, product_id, variant_id) DO UPDATE SET price = EXCLUDED.price`,
+			storeID, unit.productID, price.VariantID, price.Price)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "store_prices", storeID, nil, prices); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// storeKey resolves the store a request acts for to the key its stock is
+// kept under: the store id, or 0 for the default store. Store 0 is the
+// default store too.
+func storeKey(q querier, storeID int) (int, error) {
+	if storeID == 0 {
+		return 0, nil
+	}
+	var isDefault bool
+	err := q.QueryRow("SELECT is_default FROM stores WHERE id = $1", storeID).Scan(&isDefault)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("store id %d not found", storeID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if isDefault {
+		return 0, nil
+	}
+	return storeID, nil
+}
+
+// storeStockExpr is the stock a product or variant row has at the store
+// keyed by storeParam. Stores other than the default keep their stock in
+// store_stock; stockCol holds the stock of all stores together, so the
+// default store has whatever the others do not.
+func storeStockExpr(stockCol, productCol, variantCol, storeParam string) string {
+	return fmt.Sprintf(`CASE WHEN %[4]s = 0
+		THEN %[1]s - COALESCE((SELECT SUM(ss.stock) FROM store_stock ss WHERE ss.product_id = %[2]s AND ss.variant_id = %[3]s), 0)
+		ELSE COALESCE((SELECT ss.stock FROM store_stock ss WHERE ss.store_id = %[4]s AND ss.product_id = %[2]s AND ss.variant_id = %[3]s), 0) END`,
+		stockCol, productCol, variantCol, storeParam)
+}
+
+// storePriceExpr is the price a product or variant sells at in the store
+// keyed by storeParam: its override there, or priceCol.
+func storePriceExpr(priceCol, productCol, variantCol, storeParam string) string {
+	return fmt.Sprintf(`COALESCE((SELECT ss.price FROM store_stock ss WHERE ss.store_id = %[4]s AND ss.product_id = %[2]s AND ss.variant_id = %[3]s), %[1]s)`,
+		priceCol, productCol, variantCol, storeParam)
+}
+
+// storeStock reads the stock a product or variant has at a store.
+func storeStock(q querier, productID, variantID, key int) (int, error) {
+	table, id := stockTable(productID, variantID)
+	var stock int
+	err := q.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", storeStockExpr("stock", "$2", "$3", "$4"), table), id, productID, variantID, key).Scan(&stock)
+	return stock, err
+}
+
+// storeIDExpr and storeNameExpr name the store of a row whose store column
+// is col, where NULL is the default store.
+func storeIDExpr(col string) string {
+	return "COALESCE(" + col + ", (SELECT id FROM stores WHERE is_default), 0)"
+}
+
+func storeNameExpr(col string) string {
+	return "COALESCE((SELECT name FROM stores WHERE id = COALESCE(" + col + ", (SELECT id FROM stores WHERE is_default))), '')"
+}
@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+const supplierSelect = "SELECT id, name, phone, email, address, note FROM suppliers"
+
+func scanSupplier(row rowScanner, s *models.Supplier) error {
+	return row.Scan(&s.ID, &s.Name, &s.Phone, &s.Email, &s.Address, &s.Note)
+}
+
+func (r *SupplierRepository) GetAll(name string) ([]models.Supplier, error) {
+	query := supplierSelect
+	args := []interface{}{}
+	if name != "" {
+		query += " WHERE name ILIKE $1"
+		args = append(args, "%"+name+"%")
+	}
+	query += " ORDER BY name"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := make([]models.Supplier, 0)
+	for rows.Next() {
+		var supplier models.Supplier
+		if err := scanSupplier(rows, &supplier); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+
+	return suppliers, rows.Err()
+}
+
+func (r *SupplierRepository) GetByID(id int) (*models.Supplier, error) {
+	var supplier models.Supplier
+	err := scanSupplier(r.db.QueryRow(supplierSelect+" WHERE id = $1", id), &supplier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("supplier not found")
+		}
+		return nil, err
+	}
+
+	return &supplier, nil
+}
+
+func (r *SupplierRepository) Create(supplier *models.Supplier, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO suppliers (name, phone, email, address, note) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	err = tx.QueryRow(query, supplier.Name, supplier.Phone, supplier.Email, supplier.Address, supplier.Note).Scan(&supplier.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "supplier", supplier.ID, nil, supplier); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SupplierRepository) Update(supplier *models.Supplier, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockSupplier(tx, supplier.ID)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE suppliers SET name = $1, phone = $2, email = $3, address = $4, note = $5, updated_at = NOW() WHERE id = $6"
+	_, err = tx.Exec(query, supplier.Name, supplier.Phone, supplier.Email, supplier.Address, supplier.Note, supplier.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "supplier", supplier.ID, before, supplier); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SupplierRepository) Delete(id int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockSupplier(tx, id)
+	if err != nil {
+		return err
+	}
+
+	var ordered bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM purchase_orders WHERE supplier_id = $1)", id).Scan(&ordered)
+	if err != nil {
+		return err
+	}
+	if ordered {
+		return fmt.Errorf("supplier %s has purchase orders and cannot be deleted", before.Name)
+	}
+
+	_, err = tx.Exec("DELETE FROM supplier_products WHERE supplier_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM suppliers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditDelete, "supplier", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lockSupplier(tx *sql.Tx, id int) (*models.Supplier, error) {
+	var supplier models.Supplier
+	err := scanSupplier(tx.QueryRow(supplierSelect+" WHERE id = $1 FOR UPDATE", id), &supplier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("supplier not found")
+		}
+		return nil, err
+	}
+
+	return &supplier, nil
+}
+
+// GetProducts lists the products a supplier sells, with their current
+// stock.
+func (r *SupplierRepository) GetProducts(supplierID int) ([]models.SupplierProduct, error) {
+	return getSupplierProducts(r.db, "sp.supplier_id = $1", supplierID)
+}
+
+// SetProducts adds or updates product links of a supplier. Links not
+// listed are kept.
+func (r *SupplierRepository) SetProducts(supplierID int, links []models.SupplierProduct, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockSupplier(tx, supplierID); err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: link.ProductID, VariantID: link.VariantID}, "", false, 0)
+		if err != nil {
+			return err
+		}
+		if len(unit.components) > 0 {
+			return fmt.Errorf("%s is made from components, link the components instead", unit.name)
+		}
+		_, err = tx.Exec(`INSERT INTO supplier_products (supplier_id, product_id, variant_id, supplier_sku, last_cost, min_stock, max_stock)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (supplier_id, product_id, variant_id) DO UPDATE SET supplier_sku = EXCLUDED.supplier_sku,
+			last_cost = EXCLUDED.last_cost, min_stock = EXCLUDED.min_stock, max_stock = EXCLUDED.max_stock`,
+			supplierID, unit.productID, link.VariantID, link.SupplierSKU, link.LastCost, link.MinStock, link.MaxStock)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeAudit(tx, actor, AuditUpdate, "supplier_products", supplierID, nil, links); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SupplierRepository) DeleteProduct(supplierID, productID, variantID int, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM supplier_products WHERE supplier_id = $1 AND product_id = $2 AND variant_id = $3", supplierID, productID, variantID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("supplier product not found")
+	}
+
+	before := models.SupplierProduct{SupplierID: supplierID, ProductID: productID, VariantID: variantID}
+	if err := writeAudit(tx, actor, AuditDelete, "supplier_products", supplierID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func getSupplierProducts(q querier, where string, args ...interface{}) ([]models.SupplierProduct, error) {
+	rows, err := q.Query(`SELECT sp.supplier_id, s.name, sp.product_id, sp.variant_id, p.name, COALESCE(v.name, ''),
+			sp.supplier_sku, sp.last_cost, sp.min_stock, sp.max_stock, COALESCE(v.stock, p.stock)
+		FROM supplier_products sp
+		JOIN suppliers s ON s.id = sp.supplier_id
+		JOIN products p ON p.id = sp.product_id
+		LEFT JOIN product_variants v ON v.id = sp.variant_id
+		WHERE `+where+`
+		ORDER BY p.name, v.name, sp.last_cost, sp.supplier_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]models.SupplierProduct, 0)
+	for rows.Next() {
+		var link models.SupplierProduct
+		var variant string
+		err := rows.Scan(&link.SupplierID, &link.SupplierName, &link.ProductID, &link.VariantID, &link.Name, &variant,
+			&link.SupplierSKU, &link.LastCost, &link.MinStock, &link.MaxStock, &link.Stock)
+		if err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			link.Name += " (" + variant + ")"
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
@@ -1,7 +1,9 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"kasir-api/models"
 	"strings"
@@ -9,102 +11,824 @@ import (
 )
 
 type TransactionRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	receipts *ReceiptNumberGenerator
+	ledger   *StockLedger
+	day      models.BusinessDay
 }
 
-func NewTransactionRepository(db *sql.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+func NewTransactionRepository(db *sql.DB, receipts *ReceiptNumberGenerator, ledger *StockLedger, day models.BusinessDay) *TransactionRepository {
+	return &TransactionRepository{db: db, receipts: receipts, ledger: ledger, day: day}
 }
 
-func (repo *TransactionRepository) CreateTransaction(items []models.CheckoutItem) (*models.Transaction, error) {
+// BusinessDay is the business day sales are counted by.
+func (repo *TransactionRepository) BusinessDay() models.BusinessDay {
+	return repo.day
+}
+
+// CreateTransaction rings up a sale at the store the actor works in, at the
+// store's prices and out of its stock. A cart being checked out stays
+// locked until the sale commits, so it cannot be sold twice.
+func (repo *TransactionRepository) CreateTransaction(req models.CheckoutRequest) (*models.Transaction, error) {
 	tx, err := repo.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	totalAmount := 0
-	details := make([]models.TransactionDetail, 0)
+	var cart *models.Cart
+	if req.CartID != 0 {
+		cart, err = lockCartForCheckout(tx, req.CartID)
+		if err != nil {
+			return nil, err
+		}
+		req.Items, req.ReservationRef = cart.Items, cart.ReservationRef()
+	}
 
-	for _, item := range items {
-		var productPrice, stock int
-		var productName string
+	storeID, err := storeKey(tx, req.Actor.StoreID)
+	if err != nil {
+		return nil, err
+	}
 
-		err := tx.QueryRow("SELECT name, price, stock FROM products WHERE id = $1", item.ProductID).Scan(&productName, &productPrice, &stock)
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product id %d not found", item.ProductID)
-		}
+	totalAmount, details, err := repo.priceItems(tx, req.Items, req.ReservationRef, true, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	paidAmount, changeAmount, err := settlePayments(totalAmount, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := releaseReservations(tx, req.ReservationRef); err != nil {
+		return nil, err
+	}
+
+	if req.CustomerID != 0 {
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)", req.CustomerID).Scan(&exists)
 		if err != nil {
 			return nil, err
 		}
+		if !exists {
+			return nil, fmt.Errorf("customer id %d not found", req.CustomerID)
+		}
+	}
 
-		subtotal := productPrice * item.Quantity
-		totalAmount += subtotal
+	pointsPaid := 0
+	for _, p := range req.Payments {
+		if p.Method == models.PaymentMethodPoints {
+			pointsPaid += p.Amount
+		}
+	}
+	var loyalty *models.LoyaltyRules
+	pointsRedeemed := 0
+	if req.CustomerID != 0 || pointsPaid > 0 {
+		loyalty, err = loadLoyaltyRules(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pointsPaid > 0 {
+		pointsRedeemed, err = redeemPoints(tx, loyalty, req.CustomerID, pointsPaid)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		_, err = tx.Exec("UPDATE products SET stock = stock - $1 WHERE id = $2", item.Quantity, item.ProductID)
+	shiftID := 0
+	if req.CashierID != 0 {
+		shiftID, err = openShiftFor(tx, req.CashierID)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		details = append(details, models.TransactionDetail{
-			ProductID:   item.ProductID,
-			ProductName: productName,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
-		})
+	// The default store numbers its receipts with the configured store
+	// code, other stores with their own.
+	storeCode := ""
+	if storeID != 0 {
+		if err := tx.QueryRow("SELECT code FROM stores WHERE id = $1", storeID).Scan(&storeCode); err != nil {
+			return nil, err
+		}
+	}
+	receiptNumber, err := repo.receipts.Next(tx, time.Now(), storeCode)
+	if err != nil {
+		return nil, err
 	}
 
 	var transactionID int
 	var createdAt time.Time
-	err = tx.QueryRow("INSERT INTO transactions (total_amount) VALUES ($1) RETURNING id, created_at", totalAmount).Scan(&transactionID, &createdAt)
+	err = tx.QueryRow(`INSERT INTO transactions (receipt_number, store_id, cashier_id, register_id, shift_id, customer_id, total_amount, paid_amount, change_amount)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, 0), NULLIF($6, 0), $7, $8, $9) RETURNING id, created_at`,
+		receiptNumber, storeID, req.CashierID, req.RegisterID, shiftID, req.CustomerID, totalAmount, paidAmount, changeAmount).Scan(&transactionID, &createdAt)
 	if err != nil {
 		return nil, err
 	}
+	if cart != nil {
+		if err := markCheckedOut(tx, cart, transactionID, req.Actor); err != nil {
+			return nil, err
+		}
+	}
 
-	query := `INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES `
+	// Stock is taken through the ledger, which costs each line at the
+	// layers it consumed rather than the product's current cost price.
+	// Lot-tracked stock is sold first expiring first out.
+	sale := stockReference{models.StockMovementSale, "transaction", transactionID, storeID}
+	today := repo.day.Date(time.Now())
+	for i := range details {
+		d := &details[i]
+		draws := []models.DetailComponent{{ComponentID: d.ProductID, Quantity: d.Quantity}}
+		variantID := d.VariantID
+		if len(d.Components) > 0 {
+			draws, variantID = d.Components, 0
+		}
+		d.Cost = 0
+		for _, draw := range draws {
+			cost, err := repo.ledger.consume(tx, draw.ComponentID, variantID, draw.Quantity, sale)
+			if err != nil {
+				return nil, err
+			}
+			d.Cost += cost
+
+			tracked, err := lotTracked(tx, draw.ComponentID)
+			if err != nil {
+				return nil, err
+			}
+			if tracked {
+				lots, err := takeLots(tx, draw.ComponentID, variantID, storeID, draw.Quantity, today)
+				if err != nil {
+					return nil, err
+				}
+				d.Lots = append(d.Lots, lots...)
+			}
+		}
+	}
+
+	query := `INSERT INTO transaction_details (transaction_id, product_id, variant_id, quantity, subtotal, cost) VALUES `
 
 	args := []interface{}{}
 	placeholders := []string{}
 
 	for i, d := range details {
-		base := i * 4
+		base := i * 6
 		placeholders = append(
 			placeholders,
-			fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4),
+			fmt.Sprintf("($%d, $%d, NULLIF($%d, 0), $%d, $%d, $%d)", base+1, base+2, base+3, base+4, base+5, base+6),
 		)
 
 		args = append(args,
 			transactionID,
 			d.ProductID,
+			d.VariantID,
 			d.Quantity,
 			d.Subtotal,
+			d.Cost,
 		)
 	}
 
-	query += strings.Join(placeholders, ", ")
+	query += strings.Join(placeholders, ", ") + " RETURNING id"
+
+	detailRows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; detailRows.Next(); i++ {
+		if err := detailRows.Scan(&details[i].ID); err != nil {
+			detailRows.Close()
+			return nil, err
+		}
+		details[i].TransactionID = transactionID
+	}
+	detailRows.Close()
+	if err := detailRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, d := range details {
+		for _, m := range d.Modifiers {
+			_, err = tx.Exec("INSERT INTO transaction_detail_modifiers (detail_id, modifier_id, name, price_delta) VALUES ($1, $2, $3, $4)",
+				d.ID, m.ModifierID, m.Name, m.PriceDelta)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, c := range d.Components {
+			_, err = tx.Exec("INSERT INTO transaction_detail_components (detail_id, component_id, quantity) VALUES ($1, $2, $3)",
+				d.ID, c.ComponentID, c.Quantity)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, l := range d.Lots {
+			_, err = tx.Exec("INSERT INTO transaction_detail_lots (detail_id, product_id, lot_id, lot_number, expires_at, quantity) VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, $6)",
+				d.ID, l.ProductID, l.LotID, l.LotNumber, l.ExpiresAt, l.Quantity)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pointsEarned := 0
+	if req.CustomerID != 0 {
+		if err := postPoints(tx, req.CustomerID, transactionID, models.LoyaltyRedeem, -pointsRedeemed); err != nil {
+			return nil, err
+		}
+		pointsEarned, err = earnedPoints(tx, loyalty, details, totalAmount, pointsPaid)
+		if err != nil {
+			return nil, err
+		}
+		if err := postPoints(tx, req.CustomerID, transactionID, models.LoyaltyEarn, pointsEarned); err != nil {
+			return nil, err
+		}
+	}
+
+	payments := make([]models.Payment, 0, len(req.Payments))
+	for _, p := range req.Payments {
+		if p.Method == models.PaymentMethodGiftCard {
+			if err := redeemGiftCard(tx, p.Reference, p.Amount, transactionID); err != nil {
+				return nil, err
+			}
+			p.Reference = normalizeGiftCardCode(p.Reference)
+		}
+		_, err = tx.Exec("INSERT INTO transaction_payments (transaction_id, method, amount, reference) VALUES ($1, $2, $3, $4)",
+			transactionID, p.Method, p.Amount, p.Reference)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	transaction := &models.Transaction{
+		ID:             transactionID,
+		ReceiptNumber:  receiptNumber,
+		StoreID:        storeID,
+		CashierID:      req.CashierID,
+		RegisterID:     req.RegisterID,
+		ShiftID:        shiftID,
+		CustomerID:     req.CustomerID,
+		TotalAmount:    totalAmount,
+		PaidAmount:     paidAmount,
+		ChangeAmount:   changeAmount,
+		CreatedAt:      createdAt,
+		Details:        details,
+		Payments:       payments,
+		PointsEarned:   pointsEarned,
+		PointsRedeemed: pointsRedeemed,
+	}
+
+	if err := writeAudit(tx, req.Actor, AuditCheckout, "transaction", transactionID, nil, transaction); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+const transactionSelect = `SELECT t.id, COALESCE(t.receipt_number, ''), COALESCE(t.store_id, 0), COALESCE(t.cashier_id, 0), COALESCE(u.name, ''),
+	COALESCE(t.register_id, ''), COALESCE(t.shift_id, 0), COALESCE(t.customer_id, 0), t.total_amount, COALESCE(t.paid_amount, t.total_amount), COALESCE(t.change_amount, 0), t.created_at, t.refunded_at
+	FROM transactions t
+	LEFT JOIN users u ON u.id = t.cashier_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransaction(row rowScanner, t *models.Transaction) error {
+	return row.Scan(&t.ID, &t.ReceiptNumber, &t.StoreID, &t.CashierID, &t.CashierName, &t.RegisterID, &t.ShiftID, &t.CustomerID, &t.TotalAmount, &t.PaidAmount, &t.ChangeAmount, &t.CreatedAt, &t.RefundedAt)
+}
+
+// GetAll lists transactions newest first without their details, for the
+// transaction history.
+func (repo *TransactionRepository) GetAll(filter models.TransactionFilter) ([]models.Transaction, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.CashierID != 0 {
+		args = append(args, filter.CashierID)
+		conditions = append(conditions, fmt.Sprintf("t.cashier_id = $%d", len(args)))
+	}
+	if filter.CustomerID != 0 {
+		args = append(args, filter.CustomerID)
+		conditions = append(conditions, fmt.Sprintf("t.customer_id = $%d", len(args)))
+	}
+	if filter.RegisterID != "" {
+		args = append(args, filter.RegisterID)
+		conditions = append(conditions, fmt.Sprintf("t.register_id = $%d", len(args)))
+	}
+	if filter.StoreID != 0 {
+		key, err := storeKey(repo.db, filter.StoreID)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, key)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(t.store_id, 0) = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("t.created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("t.created_at < $%d", len(args)))
+	}
+
+	query := transactionSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY t.created_at DESC LIMIT %d", limit)
+
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+	for rows.Next() {
+		var t models.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (repo *TransactionRepository) GetByID(id int) (*models.Transaction, error) {
+	return repo.getTransaction("t.id = $1", id)
+}
+
+func (repo *TransactionRepository) GetByReceiptNumber(number string) (*models.Transaction, error) {
+	return repo.getTransaction("t.receipt_number = $1", number)
+}
+
+func (repo *TransactionRepository) getTransaction(where string, arg interface{}) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := scanTransaction(repo.db.QueryRow(transactionSelect+" WHERE "+where, arg), &transaction)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("transaction not found")
+		}
+		return nil, err
+	}
+
+	rows, err := repo.db.Query(`SELECT d.id, d.transaction_id, d.product_id, COALESCE(p.name, ''), COALESCE(d.variant_id, 0), COALESCE(v.name, ''), d.quantity, d.subtotal, COALESCE(d.cost, 0)
+		FROM transaction_details d
+		LEFT JOIN products p ON p.id = d.product_id
+		LEFT JOIN product_variants v ON v.id = d.variant_id
+		WHERE d.transaction_id = $1
+		ORDER BY d.id`, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transaction.Details = make([]models.TransactionDetail, 0)
+	for rows.Next() {
+		var d models.TransactionDetail
+		err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.ProductName, &d.VariantID, &d.VariantName, &d.Quantity, &d.Subtotal, &d.Cost)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Details = append(transaction.Details, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	modifierRows, err := repo.db.Query(`SELECT dm.detail_id, COALESCE(dm.modifier_id, 0), dm.name, dm.price_delta
+		FROM transaction_detail_modifiers dm
+		JOIN transaction_details d ON d.id = dm.detail_id
+		WHERE d.transaction_id = $1
+		ORDER BY dm.id`, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer modifierRows.Close()
+
+	for modifierRows.Next() {
+		var detailID int
+		var m models.DetailModifier
+		if err := modifierRows.Scan(&detailID, &m.ModifierID, &m.Name, &m.PriceDelta); err != nil {
+			return nil, err
+		}
+		for i := range transaction.Details {
+			if transaction.Details[i].ID == detailID {
+				transaction.Details[i].Modifiers = append(transaction.Details[i].Modifiers, m)
+			}
+		}
+	}
+	if err := modifierRows.Err(); err != nil {
+		return nil, err
+	}
+
+	componentRows, err := repo.db.Query(`SELECT c.detail_id, c.component_id, COALESCE(p.name, ''), c.quantity
+		FROM transaction_detail_components c
+		JOIN transaction_details d ON d.id = c.detail_id
+		LEFT JOIN products p ON p.id = c.component_id
+		WHERE d.transaction_id = $1
+		ORDER BY c.id`, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer componentRows.Close()
+
+	for componentRows.Next() {
+		var detailID int
+		var c models.DetailComponent
+		if err := componentRows.Scan(&detailID, &c.ComponentID, &c.Name, &c.Quantity); err != nil {
+			return nil, err
+		}
+		for i := range transaction.Details {
+			if transaction.Details[i].ID == detailID {
+				transaction.Details[i].Components = append(transaction.Details[i].Components, c)
+			}
+		}
+	}
+	if err := componentRows.Err(); err != nil {
+		return nil, err
+	}
+
+	lotRows, err := repo.db.Query(`SELECT l.detail_id, l.product_id, COALESCE(l.lot_id, 0), l.lot_number, COALESCE(TO_CHAR(l.expires_at, 'YYYY-MM-DD'), ''), l.quantity
+		FROM transaction_detail_lots l
+		JOIN transaction_details d ON d.id = l.detail_id
+		WHERE d.transaction_id = $1
+		ORDER BY l.id`, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer lotRows.Close()
+
+	for lotRows.Next() {
+		var detailID int
+		var l models.DetailLot
+		if err := lotRows.Scan(&detailID, &l.ProductID, &l.LotID, &l.LotNumber, &l.ExpiresAt, &l.Quantity); err != nil {
+			return nil, err
+		}
+		for i := range transaction.Details {
+			if transaction.Details[i].ID == detailID {
+				transaction.Details[i].Lots = append(transaction.Details[i].Lots, l)
+			}
+		}
+	}
+	if err := lotRows.Err(); err != nil {
+		return nil, err
+	}
+
+	paymentRows, err := repo.db.Query("SELECT method, amount, reference FROM transaction_payments WHERE transaction_id = $1 ORDER BY id", transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer paymentRows.Close()
+
+	transaction.Payments = make([]models.Payment, 0)
+	for paymentRows.Next() {
+		var p models.Payment
+		if err := paymentRows.Scan(&p.Method, &p.Amount, &p.Reference); err != nil {
+			return nil, err
+		}
+		transaction.Payments = append(transaction.Payments, p)
+	}
+	if err := paymentRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// RefundTransaction reverses a whole sale: stock goes back on the shelf,
+// loyalty points earned are clawed back, and points and gift card balance
+// spent are returned. Cash
+// handed back is recorded as a cash-out on the refunding cashier's open
+// shift.
+func (repo *TransactionRepository) RefundTransaction(id int, actor models.Actor) (*models.Transaction, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before models.Transaction
+	err = scanTransaction(tx.QueryRow(transactionSelect+" WHERE t.id = $1 FOR UPDATE OF t", id), &before)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("transaction not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if before.RefundedAt != nil {
+		return nil, fmt.Errorf("transaction %s is already refunded", before.ReceiptNumber)
+	}
+
+	sale := stockReference{models.StockMovementSale, "transaction", id, before.StoreID}
+	refund := stockReference{models.StockMovementRefund, "transaction", id, before.StoreID}
+	reversed, err := repo.ledger.reverse(tx, sale, refund)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE product_lots pl SET quantity = pl.quantity + l.quantity
+		FROM (SELECT l.lot_id, SUM(l.quantity) AS quantity FROM transaction_detail_lots l
+			JOIN transaction_details d ON d.id = l.detail_id
+			WHERE d.transaction_id = $1 GROUP BY l.lot_id) l
+		WHERE pl.id = l.lot_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	if reversed == 0 {
+		// Sold before stock went through the ledger: restock the lines
+		// at their current cost price.
+		if err := repo.restockDetails(tx, id, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := reversePoints(tx, id); err != nil {
+		return nil, err
+	}
+	if err := reverseGiftCards(tx, id); err != nil {
+		return nil, err
+	}
+
+	var cashBack int
+	err = tx.QueryRow(`SELECT CASE WHEN EXISTS (SELECT 1 FROM transaction_payments WHERE transaction_id = $1)
+		THEN COALESCE((SELECT SUM(amount) FROM transaction_payments WHERE transaction_id = $1 AND method = $2), 0) - $3
+		ELSE $4 END`, id, models.PaymentMethodCash, before.ChangeAmount, before.TotalAmount).Scan(&cashBack)
+	if err != nil {
+		return nil, err
+	}
+	if cashBack > 0 {
+		// Cash paid back leaves the refunding user's drawer, so it needs
+		// their open shift to account for it.
+		shiftID, err := openShiftFor(tx, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("INSERT INTO shift_cash_movements (shift_id, type, amount, reason, created_by) VALUES ($1, $2, $3, $4, $5)",
+			shiftID, models.CashMovementOut, cashBack, "refund "+before.ReceiptNumber, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	_, err = tx.Exec(query, args...)
+	var refundedAt time.Time
+	err = tx.QueryRow("UPDATE transactions SET refunded_at = NOW(), refunded_by = NULLIF($1, 0) WHERE id = $2 RETURNING refunded_at", actor.UserID, id).Scan(&refundedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	after := before
+	after.RefundedAt = &refundedAt
+	if err := writeAudit(tx, actor, AuditRefund, "transaction", id, before, after); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
+	return repo.GetByID(id)
+}
+
+func (repo *TransactionRepository) restockDetails(tx *sql.Tx, id int, ref stockReference) error {
+	rows, err := tx.Query(`SELECT td.product_id, COALESCE(td.variant_id, 0), td.quantity FROM transaction_details td
+		WHERE td.transaction_id = $1 AND NOT EXISTS (SELECT 1 FROM transaction_detail_components c WHERE c.detail_id = td.id)
+		UNION ALL
+		SELECT c.component_id, 0, c.quantity FROM transaction_detail_components c
+		JOIN transaction_details td ON td.id = c.detail_id
+		WHERE td.transaction_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	type line struct{ productID, variantID, quantity int }
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.productID, &l.variantID, &l.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		if _, err := repo.ledger.adjust(tx, l.productID, l.variantID, l.quantity, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreviewTransaction prices the items exactly like CreateTransaction but
+// rolls everything back, so nothing is stored and no stock is taken.
+func (repo *TransactionRepository) PreviewTransaction(req models.CheckoutRequest) (*models.Transaction, error) {
+	tx, err := repo.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	storeID, err := storeKey(tx, req.Actor.StoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	totalAmount, details, err := repo.priceItems(tx, req.Items, req.ReservationRef, false, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	paidAmount, changeAmount, err := settlePayments(totalAmount, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+
+	payments := req.Payments
+	if payments == nil {
+		payments = make([]models.Payment, 0)
+	}
+
 	return &models.Transaction{
-		ID:          transactionID,
-		TotalAmount: totalAmount,
-		CreatedAt:   createdAt,
-		Details:     details,
+		TotalAmount:  totalAmount,
+		PaidAmount:   paidAmount,
+		ChangeAmount: changeAmount,
+		CreatedAt:    time.Now(),
+		Details:      details,
+		Payments:     payments,
 	}, nil
 }
 
-func (repo *TransactionRepository) GetReportToday() (*models.Report, error) {
+// settlePayments checks the tendered payments against the total and returns
+// the amount paid and the change due. Only cash may overpay. A checkout
+// without payments is treated as paid in full, as it was before payments
+// were recorded.
+func settlePayments(totalAmount int, payments []models.Payment) (int, int, error) {
+	if len(payments) == 0 {
+		return totalAmount, 0, nil
+	}
+
+	paid, cash := 0, 0
+	for _, p := range payments {
+		switch p.Method {
+		case models.PaymentMethodCash, models.PaymentMethodCard, models.PaymentMethodQRIS, models.PaymentMethodPoints:
+		case models.PaymentMethodGiftCard:
+			if p.Reference == "" {
+				return 0, 0, errors.New("gift card payment needs the card code as reference")
+			}
+		default:
+			return 0, 0, fmt.Errorf("unknown payment method %q", p.Method)
+		}
+		if p.Amount <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s payment amount %d", p.Method, p.Amount)
+		}
+		paid += p.Amount
+		if p.Method == models.PaymentMethodCash {
+			cash += p.Amount
+		}
+	}
+
+	if paid < totalAmount {
+		return 0, 0, fmt.Errorf("payments of %d do not cover total %d", paid, totalAmount)
+	}
+	change := paid - totalAmount
+	if change > cash {
+		return 0, 0, errors.New("only cash payments can exceed the total")
+	}
+
+	return paid, change, nil
+}
+
+// priceItems is the single place checkout pricing and stock validation
+// live. Both CreateTransaction and PreviewTransaction go through it so the
+// quoted total can never drift from the charged one. When lock is true the
+// product and variant rows are locked until tx ends. Stock held by active
+// reservations counts as unavailable unless it is reserved under
+// reservationRef. Prices and stock are those of the store keyed by
+// storeID. Selected modifiers are priced into each line, and composite
+// products are checked against their components' stock.
+func (repo *TransactionRepository) priceItems(tx *sql.Tx, items []models.CheckoutItem, reservationRef string, lock bool, storeID int) (int, []models.TransactionDetail, error) {
+	if len(items) == 0 {
+		return 0, nil, errors.New("checkout must contain at least one item")
+	}
+
+	totalAmount := 0
+	details := make([]models.TransactionDetail, 0, len(items))
+	type stockKey struct{ productID, variantID int }
+	requested := make(map[stockKey]int)
+
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return 0, nil, fmt.Errorf("invalid quantity %d for product id %d", item.Quantity, item.ProductID)
+		}
+
+		unit, err := loadStockUnit(tx, item, reservationRef, lock, storeID)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		var components []models.DetailComponent
+		if len(unit.components) > 0 {
+			for _, c := range unit.components {
+				key := stockKey{c.productID, 0}
+				requested[key] += c.perUnit * item.Quantity
+				if c.available < requested[key] {
+					return 0, nil, fmt.Errorf("insufficient %s for %s: requested %d, available %d", c.name, unit.label(), requested[key], c.available)
+				}
+				components = append(components, models.DetailComponent{ComponentID: c.productID, Name: c.name, Quantity: c.perUnit * item.Quantity})
+			}
+		} else {
+			key := stockKey{unit.productID, unit.variantID}
+			requested[key] += item.Quantity
+			if unit.available < requested[key] {
+				return 0, nil, fmt.Errorf("insufficient stock for %s: requested %d, available %d", unit.label(), requested[key], unit.available)
+			}
+		}
+
+		modifiers, modifierPrice, err := priceModifiers(tx, unit.productID, item.ModifierIDs)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		subtotal := (unit.price + modifierPrice) * item.Quantity
+		totalAmount += subtotal
+
+		details = append(details, models.TransactionDetail{
+			ProductID:   unit.productID,
+			ProductName: unit.name,
+			VariantID:   unit.variantID,
+			VariantName: unit.variant,
+			Modifiers:   modifiers,
+			Components:  components,
+			Quantity:    item.Quantity,
+			Subtotal:    subtotal,
+			Cost:        unit.cost * item.Quantity,
+		})
+	}
+
+	return totalAmount, details, nil
+}
+
+// GetReportToday sums the sales of the current business day of all stores
+// or, when storeID is not 0, of one. BestSeller is the product sold most by
+// quantity.
+func (repo *TransactionRepository) GetReportToday(storeID int) (*models.Report, error) {
+	store, err := storeFilter(repo.db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := repo.day.Bounds(time.Now())
+
 	var report models.Report
-	err := repo.db.QueryRow("SELECT COUNT(id) AS total_sales, SUM(total_amount) AS total_revenue FROM transactions WHERE DATE(created_at) = DATE(NOW())").Scan(&report.TotalSales, &report.TotalRevenue)
+	err = repo.db.QueryRow("SELECT COUNT(id) AS total_sales, COALESCE(SUM(total_amount), 0) AS total_revenue FROM transactions WHERE created_at >= $2 AND created_at < $3 AND refunded_at IS NULL AND ($1 < 0 OR COALESCE(store_id, 0) = $1)", store, from, to).Scan(&report.TotalSales, &report.TotalRevenue)
 	if err != nil {
 		return nil, err
 	}
+
+	err = repo.db.QueryRow(`SELECT d.product_id FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		WHERE t.created_at >= $2 AND t.created_at < $3 AND t.refunded_at IS NULL AND ($1 < 0 OR COALESCE(t.store_id, 0) = $1)
+		GROUP BY d.product_id
+		ORDER BY SUM(d.quantity) DESC, d.product_id
+		LIMIT 1`, store, from, to).Scan(&report.BestSeller)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := repo.db.Query(`SELECT COALESCE(t.cashier_id, 0), COALESCE(u.name, ''), COALESCE(SUM(t.total_amount), 0), COUNT(t.id)
+		FROM transactions t
+		LEFT JOIN users u ON u.id = t.cashier_id
+		WHERE t.created_at >= $2 AND t.created_at < $3 AND t.refunded_at IS NULL AND ($1 < 0 OR COALESCE(t.store_id, 0) = $1)
+		GROUP BY t.cashier_id, u.name
+		ORDER BY 3 DESC`, store, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report.Cashiers = make([]models.CashierReport, 0)
+	for rows.Next() {
+		var c models.CashierReport
+		if err := rows.Scan(&c.CashierID, &c.CashierName, &c.TotalRevenue, &c.TotalSales); err != nil {
+			return nil, err
+		}
+		report.Cashiers = append(report.Cashiers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return &report, nil
 }
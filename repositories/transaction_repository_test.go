@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+)
+
+func TestSettlePayments(t *testing.T) {
+	cash := func(amount int) models.Payment {
+		return models.Payment{Method: models.PaymentMethodCash, Amount: amount}
+	}
+	card := func(amount int) models.Payment {
+		return models.Payment{Method: models.PaymentMethodCard, Amount: amount}
+	}
+	tests := []struct {
+		name         string
+		total        int
+		payments     []models.Payment
+		paid, change int
+		ok           bool
+	}{
+		{"no payments is paid in full", 50000, nil, 50000, 0, true},
+		{"exact cash", 50000, []models.Payment{cash(50000)}, 50000, 0, true},
+		{"cash with change", 42500, []models.Payment{cash(50000)}, 50000, 7500, true},
+		{"card and cash split", 75000, []models.Payment{card(50000), cash(25000)}, 75000, 0, true},
+		{"change comes out of the cash part", 75000, []models.Payment{card(50000), cash(30000)}, 80000, 5000, true},
+		{"qris and points", 30000, []models.Payment{{Method: models.PaymentMethodQRIS, Amount: 20000}, {Method: models.PaymentMethodPoints, Amount: 10000}}, 30000, 0, true},
+		{"gift card with its code", 20000, []models.Payment{{Method: models.PaymentMethodGiftCard, Amount: 20000, Reference: "GC-1"}}, 20000, 0, true},
+		{"short", 50000, []models.Payment{card(30000), cash(10000)}, 0, 0, false},
+		{"card overpays", 50000, []models.Payment{card(60000)}, 0, 0, false},
+		{"change beyond the cash tendered", 50000, []models.Payment{card(48000), cash(1000), card(6000)}, 0, 0, false},
+		{"gift card without a code", 20000, []models.Payment{{Method: models.PaymentMethodGiftCard, Amount: 20000}}, 0, 0, false},
+		{"unknown method", 20000, []models.Payment{{Method: "cheque", Amount: 20000}}, 0, 0, false},
+		{"zero amount", 20000, []models.Payment{cash(20000), card(0)}, 0, 0, false},
+		{"negative amount", 20000, []models.Payment{cash(30000), card(-10000)}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid, change, err := settlePayments(tt.total, tt.payments)
+			if (err == nil) != tt.ok {
+				t.Fatalf("settlePayments error = %v, want ok %v", err, tt.ok)
+			}
+			if paid != tt.paid || change != tt.change {
+				t.Errorf("settlePayments = %d, %d, want %d, %d", paid, change, tt.paid, tt.change)
+			}
+		})
+	}
+}
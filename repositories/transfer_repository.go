@@ -0,0 +1,419 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"math"
+	"strings"
+	"time"
+)
+
+type TransferRepository struct {
+	db     *sql.DB
+	ledger *StockLedger
+	day    models.BusinessDay
+}
+
+func NewTransferRepository(db *sql.DB, ledger *StockLedger, day models.BusinessDay) *TransferRepository {
+	return &TransferRepository{db: db, ledger: ledger, day: day}
+}
+
+const transferSelect = `SELECT t.id, t.from_store_id, COALESCE(fs.name, ''), t.to_store_id, COALESCE(ts.name, ''), t.status, t.note,
+	COALESCE(t.requested_by, 0), t.requested_at, COALESCE(t.dispatched_by, 0), t.dispatched_at, COALESCE(t.received_by, 0), t.received_at, t.received_note
+	FROM stock_transfers t
+	LEFT JOIN stores fs ON fs.id = t.from_store_id
+	LEFT JOIN stores ts ON ts.id = t.to_store_id`
+
+func scanTransfer(row rowScanner, t *models.StockTransfer) error {
+	return row.Scan(&t.ID, &t.FromStoreID, &t.FromStoreName, &t.ToStoreID, &t.ToStoreName, &t.Status, &t.Note,
+		&t.RequestedBy, &t.RequestedAt, &t.DispatchedBy, &t.DispatchedAt, &t.ReceivedBy, &t.ReceivedAt, &t.ReceivedNote)
+}
+
+// GetAll lists transfers, newest first. The store filter matches transfers
+// leaving or arriving at the store. Lines are only loaded for in-transit
+// transfers, so the list shows what is on the road.
+func (r *TransferRepository) GetAll(filter models.StockTransferFilter) ([]models.StockTransfer, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.StoreID != 0 {
+		args = append(args, filter.StoreID)
+		conditions = append(conditions, fmt.Sprintf("(t.from_store_id = $%d OR t.to_store_id = $%d)", len(args), len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("t.status = $%d", len(args)))
+	}
+
+	query := transferSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY t.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]models.StockTransfer, 0)
+	for rows.Next() {
+		var t models.StockTransfer
+		if err := scanTransfer(rows, &t); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range transfers {
+		if transfers[i].Status != models.TransferInTransit {
+			continue
+		}
+		if transfers[i].Items, err = getTransferItems(r.db, transfers[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return transfers, nil
+}
+
+func (r *TransferRepository) GetByID(id int) (*models.StockTransfer, error) {
+	return getTransfer(r.db, id, false)
+}
+
+func (r *TransferRepository) Create(t *models.StockTransfer, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range []int{t.FromStoreID, t.ToStoreID} {
+		if _, err := storeKey(tx, id); err != nil {
+			return err
+		}
+	}
+
+	query := "INSERT INTO stock_transfers (from_store_id, to_store_id, status, note, requested_by) VALUES ($1, $2, $3, $4, NULLIF($5, 0)) RETURNING id"
+	err = tx.QueryRow(query, t.FromStoreID, t.ToStoreID, models.TransferRequested, t.Note, actor.UserID).Scan(&t.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range t.Items {
+		unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: item.ProductID, VariantID: item.VariantID}, "", false, 0)
+		if err != nil {
+			return err
+		}
+		if len(unit.components) > 0 {
+			return fmt.Errorf("%s is made from components, transfer the components instead", unit.name)
+		}
+		_, err = tx.Exec("INSERT INTO stock_transfer_items (transfer_id, product_id, variant_id, quantity) VALUES ($1, $2, NULLIF($3, 0), $4)",
+			t.ID, unit.productID, item.VariantID, item.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	created, err := getTransfer(tx, t.ID, false)
+	if err != nil {
+		return err
+	}
+	*t = *created
+
+	if err := writeAudit(tx, actor, AuditCreate, "stock_transfer", t.ID, nil, t); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Dispatch takes the requested quantities out of the source store, and out
+// of its lots for lot-tracked products. Stock reserved there is not
+// available for a transfer.
+func (r *TransferRepository) Dispatch(id int, actor models.Actor) (*models.StockTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTransfer(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != models.TransferRequested {
+		return nil, fmt.Errorf("transfer %d is %s and cannot be dispatched", id, before.Status)
+	}
+	from, err := storeKey(tx, before.FromStoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := stockReference{models.StockMovementTransferOut, "stock_transfer", id, from}
+	for _, item := range before.Items {
+		unit, err := loadStockUnit(tx, models.CheckoutItem{ProductID: item.ProductID, VariantID: item.VariantID}, "", true, from)
+		if err != nil {
+			return nil, err
+		}
+		if unit.available < item.Quantity {
+			return nil, fmt.Errorf("only %d of %s available at %s, %d requested", unit.available, item.Name, before.FromStoreName, item.Quantity)
+		}
+		cost, err := r.ledger.consume(tx, item.ProductID, item.VariantID, item.Quantity, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		tracked, err := lotTracked(tx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if tracked {
+			// Like a sale, a transfer ships unexpired lots only, the ones
+			// expiring first going first.
+			lots, err := takeLots(tx, item.ProductID, item.VariantID, from, item.Quantity, r.day.Date(time.Now()))
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range lots {
+				_, err = tx.Exec("INSERT INTO stock_transfer_lots (transfer_id, product_id, variant_id, lot_number, expires_at, quantity) VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, $6)",
+					id, item.ProductID, item.VariantID, l.LotNumber, l.ExpiresAt, l.Quantity)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		_, err = tx.Exec("UPDATE stock_transfer_items SET dispatched_quantity = $1, cost = $2 WHERE transfer_id = $3 AND product_id = $4 AND COALESCE(variant_id, 0) = $5",
+			item.Quantity, cost, id, item.ProductID, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE stock_transfers SET status = $1, dispatched_by = NULLIF($2, 0), dispatched_at = NOW() WHERE id = $3",
+		models.TransferInTransit, actor.UserID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitTransfer(tx, id, actor, before)
+}
+
+// Receive books everything dispatched into the destination store at the
+// cost it left the source store, so the transfer's movements out and in
+// match. Goods that went missing or turned up extra are then posted as
+// adjustments referencing the transfer. Lot-tracked goods arrive in the
+// lots they were dispatched from; the adjustments take from and add to
+// lots as stock adjustments do.
+func (r *TransferRepository) Receive(id int, receipt *models.TransferReceipt, actor models.Actor) (*models.StockTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTransfer(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != models.TransferInTransit {
+		return nil, fmt.Errorf("transfer %d is %s and cannot be received", id, before.Status)
+	}
+	to, err := storeKey(tx, before.ToStoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(map[[2]int]int, len(before.Items))
+	for _, item := range before.Items {
+		received[[2]int{item.ProductID, item.VariantID}] = item.DispatchedQuantity
+	}
+	for _, item := range receipt.Items {
+		key := [2]int{item.ProductID, item.VariantID}
+		if _, ok := received[key]; !ok {
+			return nil, fmt.Errorf("product id %d variant id %d is not on transfer %d", item.ProductID, item.VariantID, id)
+		}
+		received[key] = item.Quantity
+	}
+
+	ref := stockReference{models.StockMovementTransferIn, "stock_transfer", id, to}
+	discrepancy := stockReference{models.StockMovementAdjustment, "stock_transfer", id, to}
+	for _, item := range before.Items {
+		qty := received[[2]int{item.ProductID, item.VariantID}]
+		if item.DispatchedQuantity > 0 {
+			unitCost := int(math.Round(float64(item.Cost) / float64(item.DispatchedQuantity)))
+			if err := r.ledger.receive(tx, item.ProductID, item.VariantID, item.DispatchedQuantity, unitCost, ref); err != nil {
+				return nil, err
+			}
+
+			tracked, err := lotTracked(tx, item.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			if tracked {
+				if err := receiveTransferLots(tx, id, item.ProductID, item.VariantID, to, item.DispatchedQuantity); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, err := r.ledger.adjust(tx, item.ProductID, item.VariantID, qty-item.DispatchedQuantity, discrepancy); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("UPDATE stock_transfer_items SET received_quantity = $1 WHERE transfer_id = $2 AND product_id = $3 AND COALESCE(variant_id, 0) = $4",
+			qty, id, item.ProductID, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE stock_transfers SET status = $1, received_by = NULLIF($2, 0), received_at = NOW(), received_note = $3 WHERE id = $4",
+		models.TransferReceived, actor.UserID, receipt.Note, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitTransfer(tx, id, actor, before)
+}
+
+// receiveTransferLots puts qty of a line into the destination store's lots:
+// the lots it was dispatched from first, then the lot without a number.
+func receiveTransferLots(tx *sql.Tx, transferID, productID, variantID, to, qty int) error {
+	rows, err := tx.Query(`SELECT lot_number, COALESCE(TO_CHAR(expires_at, 'YYYY-MM-DD'), ''), quantity FROM stock_transfer_lots
+		WHERE transfer_id = $1 AND product_id = $2 AND variant_id = $3
+		ORDER BY expires_at NULLS LAST, id`, transferID, productID, variantID)
+	if err != nil {
+		return err
+	}
+	var lots []models.DetailLot
+	for rows.Next() {
+		var l models.DetailLot
+		if err := rows.Scan(&l.LotNumber, &l.ExpiresAt, &l.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		if qty == 0 {
+			break
+		}
+		n := min(qty, l.Quantity)
+		if err := putLot(tx, productID, variantID, to, l.LotNumber, l.ExpiresAt, n); err != nil {
+			return err
+		}
+		qty -= n
+	}
+	if qty > 0 {
+		return putLot(tx, productID, variantID, to, "", "", qty)
+	}
+	return nil
+}
+
+// Cancel drops a transfer that has not been dispatched yet.
+func (r *TransferRepository) Cancel(id int, actor models.Actor) (*models.StockTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTransfer(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != models.TransferRequested {
+		return nil, fmt.Errorf("transfer %d is %s and cannot be cancelled", id, before.Status)
+	}
+
+	if _, err := tx.Exec("UPDATE stock_transfers SET status = $1 WHERE id = $2", models.TransferCancelled, id); err != nil {
+		return nil, err
+	}
+
+	return commitTransfer(tx, id, actor, before)
+}
+
+func commitTransfer(tx *sql.Tx, id int, actor models.Actor, before *models.StockTransfer) (*models.StockTransfer, error) {
+	after, err := getTransfer(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(tx, actor, AuditUpdate, "stock_transfer", id, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// getTransfer reads a transfer with its lines, locking the transfer row
+// when lock is true.
+func getTransfer(q querier, id int, lock bool) (*models.StockTransfer, error) {
+	query := transferSelect + " WHERE t.id = $1"
+	if lock {
+		query += " FOR UPDATE OF t"
+	}
+
+	var t models.StockTransfer
+	err := scanTransfer(q.QueryRow(query, id), &t)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("transfer not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.Items, err = getTransferItems(q, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status == models.TransferReceived {
+		for i := range t.Items {
+			t.Items[i].Discrepancy = t.Items[i].ReceivedQuantity - t.Items[i].DispatchedQuantity
+		}
+	}
+	return &t, nil
+}
+
+func getTransferItems(q querier, id int) ([]models.StockTransferItem, error) {
+	rows, err := q.Query(`SELECT i.product_id, COALESCE(i.variant_id, 0), COALESCE(p.name, ''), COALESCE(v.name, ''),
+			i.quantity, i.dispatched_quantity, i.received_quantity, i.cost
+		FROM stock_transfer_items i
+		LEFT JOIN products p ON p.id = i.product_id
+		LEFT JOIN product_variants v ON v.id = i.variant_id
+		WHERE i.transfer_id = $1
+		ORDER BY i.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.StockTransferItem, 0)
+	for rows.Next() {
+		var item models.StockTransferItem
+		var variant string
+		err := rows.Scan(&item.ProductID, &item.VariantID, &item.Name, &variant, &item.Quantity, &item.DispatchedQuantity, &item.ReceivedQuantity, &item.Cost)
+		if err != nil {
+			return nil, err
+		}
+		if variant != "" {
+			item.Name += " (" + variant + ")"
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
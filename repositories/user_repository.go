@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/models"
+	"time"
+)
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) GetAll() ([]models.User, error) {
+	query := "SELECT id, username, name, role, active, COALESCE(store_id, 0), created_at FROM users ORDER BY id"
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(&user.ID, &user.Username, &user.Name, &user.Role, &user.Active, &user.StoreID, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	query := "SELECT id, username, name, role, active, COALESCE(store_id, 0), created_at FROM users WHERE id = $1"
+
+	var user models.User
+	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Name, &user.Role, &user.Active, &user.StoreID, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetCredentials returns the user with the given username and its stored
+// password hash.
+func (r *UserRepository) GetCredentials(username string) (*models.User, string, error) {
+	query := "SELECT id, username, name, role, active, COALESCE(store_id, 0), created_at, password_hash FROM users WHERE username = $1"
+
+	var user models.User
+	var passwordHash string
+	err := r.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Name, &user.Role, &user.Active, &user.StoreID, &user.CreatedAt, &passwordHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", errors.New("user not found")
+		}
+		return nil, "", err
+	}
+
+	return &user, passwordHash, nil
+}
+
+func (r *UserRepository) Count() (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// Create adds a user. An empty actor is the server itself, creating the
+// first owner.
+func (r *UserRepository) Create(user *models.User, passwordHash string, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO users (username, name, role, active, store_id, password_hash) VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6) RETURNING id, created_at"
+	err = tx.QueryRow(query, user.Username, user.Name, user.Role, user.Active, user.StoreID, passwordHash).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(tx, actor, AuditCreate, "user", user.ID, nil, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update changes a user, and their password when passwordHash is not
+// empty. The audit log records that the password changed, never the hash.
+func (r *UserRepository) Update(user *models.User, passwordHash string, actor models.Actor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before models.User
+	err = tx.QueryRow("SELECT id, username, name, role, active, COALESCE(store_id, 0), created_at FROM users WHERE id = $1 FOR UPDATE", user.ID).
+		Scan(&before.ID, &before.Username, &before.Name, &before.Role, &before.Active, &before.StoreID, &before.CreatedAt)
+	if err == sql.ErrNoRows {
+		return errors.New("user not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET name = $1, role = $2, active = $3, store_id = NULLIF($4, 0), updated_at = NOW() WHERE id = $5"
+	if _, err := tx.Exec(query, user.Name, user.Role, user.Active, user.StoreID, user.ID); err != nil {
+		return err
+	}
+	user.Username, user.CreatedAt = before.Username, before.CreatedAt
+	if err := writeAudit(tx, actor, AuditUpdate, "user", user.ID, before, user); err != nil {
+		return err
+	}
+
+	if passwordHash != "" {
+		if _, err := tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, user.ID); err != nil {
+			return err
+		}
+
+		// a new password signs the user out everywhere
+		_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", user.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := writeAudit(tx, actor, AuditUpdate, "user_password", user.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) SaveRefreshToken(userID int, tokenHash string, expiresAt time.Time) error {
+	query := "INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)"
+	_, err := r.db.Exec(query, userID, tokenHash, expiresAt)
+	return err
+}
+
+// RotateRefreshToken revokes a valid refresh token, stores its replacement
+// and returns the owning user. A token can only be used once.
+func (r *UserRepository) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+	err = tx.QueryRow(query, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("invalid refresh token")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)", userID, newTokenHash, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+func (r *UserRepository) RevokeRefreshToken(tokenHash string) error {
+	_, err := r.db.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL", tokenHash)
+	return err
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type AuditService struct {
+	repo *repositories.AuditRepository
+	day  models.BusinessDay
+}
+
+func NewAuditService(repo *repositories.AuditRepository, day models.BusinessDay) *AuditService {
+	return &AuditService{repo: repo, day: day}
+}
+
+// BusinessDay is the business day audit dates are read in.
+func (s *AuditService) BusinessDay() models.BusinessDay {
+	return s.day
+}
+
+func (s *AuditService) GetAll(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return s.repo.GetAll(filter)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// dummyHash lets failed lookups spend as long as a password check, so
+// response times do not reveal which usernames exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kasir-api-dummy"), bcrypt.DefaultCost)
+
+type AuthService struct {
+	repo       *repositories.UserRepository
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewAuthService(repo *repositories.UserRepository, secret string, accessTTL, refreshTTL time.Duration) (*AuthService, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("JWT_SECRET must be at least 32 characters")
+	}
+	if accessTTL <= 0 {
+		accessTTL = 15 * time.Minute
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = 7 * 24 * time.Hour
+	}
+	return &AuthService{repo: repo, secret: []byte(secret), accessTTL: accessTTL, refreshTTL: refreshTTL}, nil
+}
+
+// EnsureOwner creates the first owner account when the users table is
+// empty, so a fresh install can log in at all.
+func (s *AuthService) EnsureOwner(username, password string) error {
+	count, err := s.repo.Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	if username == "" || password == "" {
+		return errors.New("no users yet: set ADMIN_USERNAME and ADMIN_PASSWORD to create the first owner")
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.repo.Create(&models.User{Username: username, Name: username, Role: models.RoleOwner, Active: true}, hash, models.Actor{})
+}
+
+func (s *AuthService) Login(req models.LoginRequest) (*models.TokenResponse, error) {
+	user, hash, err := s.repo.GetCredentials(req.Username)
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil || !user.Active {
+		return nil, ErrInvalidCredentials
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveRefreshToken(user.ID, refreshHash, time.Now().Add(s.refreshTTL)); err != nil {
+		return nil, err
+	}
+
+	return s.tokenResponse(user, refreshToken)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token; the old refresh token stops working.
+func (s *AuthService) Refresh(req models.RefreshRequest) (*models.TokenResponse, error) {
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.repo.RotateRefreshToken(hashToken(req.RefreshToken), refreshHash, time.Now().Add(s.refreshTTL))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, ErrInvalidToken
+	}
+
+	return s.tokenResponse(user, refreshToken)
+}
+
+func (s *AuthService) Logout(req models.RefreshRequest) error {
+	return s.repo.RevokeRefreshToken(hashToken(req.RefreshToken))
+}
+
+func (s *AuthService) tokenResponse(user *models.User, refreshToken string) (*models.TokenResponse, error) {
+	now := time.Now()
+	accessToken, err := s.signToken(models.Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		StoreID:   user.StoreID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.accessTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (s *AuthService) signToken(claims models.Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), nil
+}
+
+// ParseToken verifies an HS256 access token and returns its claims.
+func (s *AuthService) ParseToken(token string) (*models.Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidToken
+	}
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.signature(unsigned))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims models.Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+func (s *AuthService) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// newRefreshToken returns a random opaque token and the hash it is stored
+// under; the plain token is never saved.
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
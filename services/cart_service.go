@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"time"
+)
+
+type CartService struct {
+	repo            *repositories.CartRepository
+	transactionRepo *repositories.TransactionRepository
+}
+
+func NewCartService(repo *repositories.CartRepository, transactionRepo *repositories.TransactionRepository) *CartService {
+	return &CartService{repo: repo, transactionRepo: transactionRepo}
+}
+
+func (s *CartService) GetAll(status string) ([]models.Cart, error) {
+	return s.repo.GetAll(status)
+}
+
+func (s *CartService) GetByID(id int) (*models.Cart, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *CartService) Create(cart *models.Cart, actor models.Actor) error {
+	return s.repo.Create(cart, actor)
+}
+
+func (s *CartService) AddItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	return s.repo.AddItem(cartID, item, actor)
+}
+
+func (s *CartService) UpdateItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	return s.repo.UpdateItem(cartID, item, actor)
+}
+
+func (s *CartService) RemoveItem(cartID int, item models.CheckoutItem, actor models.Actor) error {
+	return s.repo.RemoveItem(cartID, item, actor)
+}
+
+func (s *CartService) Hold(cartID int, req models.HoldCartRequest, actor models.Actor) error {
+	if req.ReserveMinutes < 0 {
+		return errors.New("reserve_minutes cannot be negative")
+	}
+	return s.repo.Hold(cartID, req.Note, time.Duration(req.ReserveMinutes)*time.Minute, req.StoreID, actor)
+}
+
+func (s *CartService) Resume(cartID int, actor models.Actor) error {
+	return s.repo.Resume(cartID, actor)
+}
+
+func (s *CartService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+// Checkout turns the cart into a transaction through the regular checkout
+// path, which closes the cart in the same database transaction so it
+// cannot be rung up twice. Items and the reservation come from the cart;
+// payments and cashier come from req.
+func (s *CartService) Checkout(cartID int, req models.CheckoutRequest) (*models.Transaction, error) {
+	req.CartID = cartID
+	return s.transactionRepo.CreateTransaction(req)
+}
@@ -21,15 +21,15 @@ func (s *CategoryService) GetByID(id int) (*models.Category, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *CategoryService) Create(category *models.Category) error {
-	return s.repo.Create(category)
+func (s *CategoryService) Create(category *models.Category, actor models.Actor) error {
+	return s.repo.Create(category, actor)
 }
 
-func (s *CategoryService) Update(id int, category *models.Category) error {
+func (s *CategoryService) Update(id int, category *models.Category, actor models.Actor) error {
 	category.ID = id
-	return s.repo.Update(category)
+	return s.repo.Update(category, actor)
 }
 
-func (s *CategoryService) Delete(id int) error {
-	return s.repo.Delete(id)
+func (s *CategoryService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
 }
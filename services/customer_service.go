@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type CustomerService struct {
+	repo            *repositories.CustomerRepository
+	transactionRepo *repositories.TransactionRepository
+}
+
+func NewCustomerService(repo *repositories.CustomerRepository, transactionRepo *repositories.TransactionRepository) *CustomerService {
+	return &CustomerService{repo: repo, transactionRepo: transactionRepo}
+}
+
+func (s *CustomerService) GetAll(name, phone string) ([]models.Customer, error) {
+	return s.repo.GetAll(name, phone)
+}
+
+func (s *CustomerService) GetByID(id int) (*models.Customer, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *CustomerService) Create(customer *models.Customer, actor models.Actor) error {
+	if customer.Name == "" {
+		return errors.New("customer name is required")
+	}
+	return s.repo.Create(customer, actor)
+}
+
+func (s *CustomerService) Update(id int, customer *models.Customer, actor models.Actor) error {
+	if customer.Name == "" {
+		return errors.New("customer name is required")
+	}
+	customer.ID = id
+	return s.repo.Update(customer, actor)
+}
+
+func (s *CustomerService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func (s *CustomerService) GetHistory(id int, limit int) (*models.CustomerHistory, error) {
+	history, err := s.repo.GetHistory(id)
+	if err != nil {
+		return nil, err
+	}
+	history.Transactions, err = s.transactionRepo.GetAll(models.TransactionFilter{CustomerID: id, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+	"time"
+)
+
+// giftCardAlphabet leaves out 0/O and 1/I so codes read back cleanly.
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type GiftCardService struct {
+	repo *repositories.GiftCardRepository
+}
+
+func NewGiftCardService(repo *repositories.GiftCardRepository) *GiftCardService {
+	return &GiftCardService{repo: repo}
+}
+
+func (s *GiftCardService) GetAll(customerID int) ([]models.GiftCard, error) {
+	return s.repo.GetAll(customerID)
+}
+
+func (s *GiftCardService) GetByCode(code string) (*models.GiftCard, error) {
+	return s.repo.GetByCode(code)
+}
+
+func (s *GiftCardService) Issue(req models.IssueGiftCardRequest, actor models.Actor) (*models.GiftCard, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("gift card amount must be greater than zero")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	code := strings.TrimSpace(req.Code)
+	if code == "" {
+		var err error
+		code, err = newGiftCardCode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	card := &models.GiftCard{
+		Code:           code,
+		InitialBalance: req.Amount,
+		CustomerID:     req.CustomerID,
+		ExpiresAt:      req.ExpiresAt,
+	}
+	if err := s.repo.Issue(card, req.PaidCash, actor); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+func (s *GiftCardService) Void(code string, actor models.Actor) error {
+	return s.repo.Void(code, actor)
+}
+
+// newGiftCardCode returns a random code like ABCD-EFGH-JKLM-NPQR.
+func newGiftCardCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardAlphabet[int(c)%len(giftCardAlphabet)])
+	}
+	return code.String(), nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"time"
+)
+
+type GoodsReceiptService struct {
+	repo *repositories.GoodsReceiptRepository
+	day  models.BusinessDay
+}
+
+func NewGoodsReceiptService(repo *repositories.GoodsReceiptRepository, day models.BusinessDay) *GoodsReceiptService {
+	return &GoodsReceiptService{repo: repo, day: day}
+}
+
+// BusinessDay is the business day receipt dates are read in.
+func (s *GoodsReceiptService) BusinessDay() models.BusinessDay {
+	return s.day
+}
+
+func (s *GoodsReceiptService) GetAll(from, to time.Time) ([]models.GoodsReceipt, error) {
+	return s.repo.GetAll(from, to)
+}
+
+func (s *GoodsReceiptService) GetByID(id int) (*models.GoodsReceipt, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *GoodsReceiptService) Create(receipt *models.GoodsReceipt, actor models.Actor) error {
+	if len(receipt.Items) == 0 {
+		return errors.New("goods receipt must contain at least one item")
+	}
+	for _, item := range receipt.Items {
+		if item.Quantity <= 0 {
+			return errors.New("received quantity must be greater than zero")
+		}
+		if item.CostPrice < 0 {
+			return errors.New("cost_price cannot be negative")
+		}
+		if err := validateExpiry(item.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	return s.repo.Create(receipt, actor)
+}
+
+func validateExpiry(expiresAt string) error {
+	if expiresAt == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", expiresAt); err != nil {
+		return errors.New("invalid expires_at, use YYYY-MM-DD")
+	}
+	return nil
+}
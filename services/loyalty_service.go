@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type LoyaltyService struct {
+	repo *repositories.LoyaltyRepository
+}
+
+func NewLoyaltyService(repo *repositories.LoyaltyRepository) *LoyaltyService {
+	return &LoyaltyService{repo: repo}
+}
+
+func (s *LoyaltyService) GetRules() (*models.LoyaltyRules, error) {
+	return s.repo.GetRules()
+}
+
+func (s *LoyaltyService) UpdateRules(rules *models.LoyaltyRules, actor models.Actor) error {
+	if rules.RupiahPerPoint <= 0 {
+		return errors.New("rupiah_per_point must be greater than zero")
+	}
+	if rules.PointValue <= 0 {
+		return errors.New("point_value must be greater than zero")
+	}
+	for _, m := range rules.CategoryMultipliers {
+		if m.Multiplier < 0 {
+			return errors.New("category multiplier cannot be negative")
+		}
+	}
+	return s.repo.UpdateRules(rules, actor)
+}
+
+func (s *LoyaltyService) GetAccount(customerID int) (*models.LoyaltyAccount, error) {
+	return s.repo.GetAccount(customerID)
+}
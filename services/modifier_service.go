@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"time"
+)
+
+type ModifierService struct {
+	repo *repositories.ModifierRepository
+	day  models.BusinessDay
+}
+
+func NewModifierService(repo *repositories.ModifierRepository, day models.BusinessDay) *ModifierService {
+	return &ModifierService{repo: repo, day: day}
+}
+
+// BusinessDay is the business day report dates are read in.
+func (s *ModifierService) BusinessDay() models.BusinessDay {
+	return s.day
+}
+
+func (s *ModifierService) GetAll() ([]models.ModifierGroup, error) {
+	return s.repo.GetAll()
+}
+
+func (s *ModifierService) GetByID(id int) (*models.ModifierGroup, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *ModifierService) Create(group *models.ModifierGroup, actor models.Actor) error {
+	if err := validateModifierGroup(group); err != nil {
+		return err
+	}
+	return s.repo.Create(group, actor)
+}
+
+func (s *ModifierService) Update(id int, group *models.ModifierGroup, actor models.Actor) error {
+	if err := validateModifierGroup(group); err != nil {
+		return err
+	}
+	group.ID = id
+	return s.repo.Update(group, actor)
+}
+
+func (s *ModifierService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func (s *ModifierService) Popularity(from, to time.Time) ([]models.ModifierPopularity, error) {
+	return s.repo.Popularity(from, to)
+}
+
+func validateModifierGroup(group *models.ModifierGroup) error {
+	if group.Name == "" {
+		return errors.New("modifier group name is required")
+	}
+	if group.MinSelect < 0 || group.MaxSelect < 0 {
+		return errors.New("min_select and max_select cannot be negative")
+	}
+	if group.MaxSelect > 0 && group.MinSelect > group.MaxSelect {
+		return errors.New("min_select cannot be greater than max_select")
+	}
+	if group.MaxSelect > len(group.Modifiers) {
+		return errors.New("max_select cannot exceed the number of modifiers")
+	}
+	for _, m := range group.Modifiers {
+		if m.Name == "" {
+			return errors.New("modifier name is required")
+		}
+	}
+	return nil
+}
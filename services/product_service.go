@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"kasir-api/models"
 	"kasir-api/repositories"
 )
@@ -13,23 +14,89 @@ func NewProductService(repo *repositories.ProductRepository) *ProductService {
 	return &ProductService{repo: repo}
 }
 
-func (s *ProductService) GetAll(name string) ([]models.Product, error) {
-	return s.repo.GetAll(name)
+func (s *ProductService) GetAll(name string, storeID int) ([]models.Product, error) {
+	return s.repo.GetAll(name, storeID)
 }
 
-func (s *ProductService) GetByID(id int) (*models.Product, error) {
-	return s.repo.GetByID(id)
+func (s *ProductService) GetByID(id, storeID int) (*models.Product, error) {
+	return s.repo.GetByID(id, storeID)
 }
 
-func (s *ProductService) Create(product *models.Product) error {
-	return s.repo.Create(product)
+func (s *ProductService) Create(product *models.Product, actor models.Actor) error {
+	if product.CostPrice < 0 {
+		return errors.New("cost_price cannot be negative")
+	}
+	if product.Stock < 0 {
+		return errors.New("stock cannot be negative")
+	}
+	for i := range product.Variants {
+		if err := validateVariant(&product.Variants[i]); err != nil {
+			return err
+		}
+	}
+	return s.repo.Create(product, actor)
 }
 
-func (s *ProductService) Update(id int, product *models.Product) error {
+func (s *ProductService) Update(id int, product *models.Product, actor models.Actor) error {
+	if product.CostPrice < 0 {
+		return errors.New("cost_price cannot be negative")
+	}
 	product.ID = id
-	return s.repo.Update(product)
+	return s.repo.Update(product, actor)
 }
 
-func (s *ProductService) Delete(id int) error {
-	return s.repo.Delete(id)
+func (s *ProductService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func (s *ProductService) CreateVariant(productID int, variant *models.ProductVariant, actor models.Actor) error {
+	if err := validateVariant(variant); err != nil {
+		return err
+	}
+	variant.ProductID = productID
+	return s.repo.CreateVariant(variant, actor)
+}
+
+func (s *ProductService) UpdateVariant(productID, id int, variant *models.ProductVariant, actor models.Actor) error {
+	if err := validateVariant(variant); err != nil {
+		return err
+	}
+	variant.ID = id
+	variant.ProductID = productID
+	return s.repo.UpdateVariant(variant, actor)
+}
+
+func (s *ProductService) DeleteVariant(productID, id int, actor models.Actor) error {
+	return s.repo.DeleteVariant(productID, id, actor)
+}
+
+func validateVariant(variant *models.ProductVariant) error {
+	if variant.SKU == "" {
+		return errors.New("variant sku is required")
+	}
+	if variant.Name == "" {
+		return errors.New("variant name is required")
+	}
+	if variant.Price < 0 || variant.CostPrice < 0 || variant.Stock < 0 {
+		return errors.New("variant price, cost and stock cannot be negative")
+	}
+	return nil
+}
+
+func (s *ProductService) GetLots(productID, storeID int) ([]models.ProductLot, error) {
+	return s.repo.GetLots(productID, storeID)
+}
+
+func (s *ProductService) SetComponents(productID int, components []models.ProductComponent, actor models.Actor) error {
+	seen := make(map[int]bool, len(components))
+	for _, c := range components {
+		if c.Quantity <= 0 {
+			return errors.New("component quantity must be greater than zero")
+		}
+		if seen[c.ComponentID] {
+			return errors.New("each component can only be listed once")
+		}
+		seen[c.ComponentID] = true
+	}
+	return s.repo.SetComponents(productID, components, actor)
 }
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type PurchaseOrderService struct {
+	repo *repositories.PurchaseOrderRepository
+}
+
+func NewPurchaseOrderService(repo *repositories.PurchaseOrderRepository) *PurchaseOrderService {
+	return &PurchaseOrderService{repo: repo}
+}
+
+func (s *PurchaseOrderService) GetAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	return s.repo.GetAll(filter)
+}
+
+func (s *PurchaseOrderService) GetByID(id int) (*models.PurchaseOrder, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Create(po *models.PurchaseOrder, actor models.Actor) error {
+	if po.SupplierID == 0 {
+		return errors.New("supplier_id is required")
+	}
+	if err := validatePurchaseOrderItems(po.Items); err != nil {
+		return err
+	}
+	return s.repo.Create(po, actor)
+}
+
+func (s *PurchaseOrderService) Update(id int, po *models.PurchaseOrder, actor models.Actor) error {
+	if err := validatePurchaseOrderItems(po.Items); err != nil {
+		return err
+	}
+	po.ID = id
+	return s.repo.Update(po, actor)
+}
+
+func (s *PurchaseOrderService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func (s *PurchaseOrderService) Send(id int, actor models.Actor) (*models.PurchaseOrder, error) {
+	return s.repo.Send(id, actor)
+}
+
+func (s *PurchaseOrderService) Close(id int, actor models.Actor) (*models.PurchaseOrder, error) {
+	return s.repo.Close(id, actor)
+}
+
+func (s *PurchaseOrderService) Receive(id int, receipt *models.GoodsReceipt, actor models.Actor) (*models.PurchaseOrder, error) {
+	if len(receipt.Items) == 0 {
+		return nil, errors.New("goods receipt must contain at least one item")
+	}
+	for _, item := range receipt.Items {
+		if item.Quantity <= 0 {
+			return nil, errors.New("received quantity must be greater than zero")
+		}
+		if item.CostPrice < 0 {
+			return nil, errors.New("cost_price cannot be negative")
+		}
+		if err := validateExpiry(item.ExpiresAt); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.Receive(id, receipt, actor)
+}
+
+func (s *PurchaseOrderService) Suggestions(supplierID, storeID int) ([]models.PurchaseOrder, error) {
+	return s.repo.Suggestions(supplierID, storeID)
+}
+
+func validatePurchaseOrderItems(items []models.PurchaseOrderItem) error {
+	if len(items) == 0 {
+		return errors.New("purchase order must contain at least one item")
+	}
+	seen := make(map[[2]int]bool, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return errors.New("ordered quantity must be greater than zero")
+		}
+		if item.UnitCost < 0 {
+			return errors.New("unit_cost cannot be negative")
+		}
+		key := [2]int{item.ProductID, item.VariantID}
+		if seen[key] {
+			return fmt.Errorf("product id %d variant id %d is listed twice", item.ProductID, item.VariantID)
+		}
+		seen[key] = true
+	}
+	return nil
+}
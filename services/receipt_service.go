@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultTextReceipt = `{{center .Store.Name}}
+{{if .Store.Address}}{{center .Store.Address}}
+{{end}}{{if .Store.Phone}}{{center .Store.Phone}}
+{{end}}{{line}}
+{{cols "No" .Transaction.ReceiptNumber}}
+{{cols "Tanggal" (datetime .Transaction.CreatedAt)}}
+{{line}}
+{{range .Transaction.Details}}{{.ProductName}}{{if .VariantName}} ({{.VariantName}}){{end}}
+{{range .Modifiers}}  + {{.Name}}
+{{end}}{{cols (printf "  %d x %s" .Quantity (rupiah (unitPrice .))) (rupiah .Subtotal)}}
+{{end}}{{line}}
+{{cols "TOTAL" (rupiah .Transaction.TotalAmount)}}
+{{range .Transaction.Payments}}{{cols (upper .Method) (rupiah .Amount)}}
+{{end}}{{if .Transaction.ChangeAmount}}{{cols "KEMBALI" (rupiah .Transaction.ChangeAmount)}}
+{{end}}{{line}}
+{{if .Footer}}{{center .Footer}}
+{{end}}`
+
+const defaultHTMLReceipt = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Transaction.ReceiptNumber}}</title>
+<style>body{font-family:monospace;max-width:320px;margin:auto}table{width:100%}td.r{text-align:right}h1,p.c{text-align:center}</style>
+</head>
+<body>
+<h1>{{.Store.Name}}</h1>
+{{if .Store.Address}}<p class="c">{{.Store.Address}}</p>{{end}}
+{{if .Store.Phone}}<p class="c">{{.Store.Phone}}</p>{{end}}
+<p>No: {{.Transaction.ReceiptNumber}}<br>Tanggal: {{datetime .Transaction.CreatedAt}}</p>
+<table>
+{{range .Transaction.Details}}<tr><td>{{.ProductName}}{{if .VariantName}} ({{.VariantName}}){{end}}{{range .Modifiers}}<br>+ {{.Name}}{{end}}<br>{{.Quantity}} x {{rupiah (unitPrice .)}}</td><td class="r">{{rupiah .Subtotal}}</td></tr>
+{{end}}<tr><td><b>TOTAL</b></td><td class="r"><b>{{rupiah .Transaction.TotalAmount}}</b></td></tr>
+{{range .Transaction.Payments}}<tr><td>{{upper .Method}}</td><td class="r">{{rupiah .Amount}}</td></tr>
+{{end}}{{if .Transaction.ChangeAmount}}<tr><td>KEMBALI</td><td class="r">{{rupiah .Transaction.ChangeAmount}}</td></tr>{{end}}
+</table>
+{{if .Footer}}<p class="c">{{.Footer}}</p>{{end}}
+</body>
+</html>
+`
+
+const (
+	ReceiptFormatText   = "text"
+	ReceiptFormatESCPOS = "escpos"
+	ReceiptFormatPDF    = "pdf"
+	ReceiptFormatHTML   = "html"
+)
+
+// ReceiptSettings holds the store details printed on every receipt and
+// optional paths to templates that replace the built-in layouts.
+type ReceiptSettings struct {
+	StoreName        string
+	StoreAddress     string
+	StorePhone       string
+	Footer           string
+	Width            int
+	TextTemplatePath string
+	HTMLTemplatePath string
+}
+
+type receiptData struct {
+	Store struct {
+		Name    string
+		Address string
+		Phone   string
+	}
+	Footer      string
+	Transaction *models.Transaction
+}
+
+type ReceiptService struct {
+	repo     *repositories.TransactionRepository
+	stores   *repositories.StoreRepository
+	settings ReceiptSettings
+	text     *template.Template
+	html     *htmltemplate.Template
+}
+
+func NewReceiptService(repo *repositories.TransactionRepository, stores *repositories.StoreRepository, settings ReceiptSettings) (*ReceiptService, error) {
+	if settings.Width <= 0 {
+		settings.Width = 32
+	}
+
+	textSource, err := readTemplate(settings.TextTemplatePath, defaultTextReceipt)
+	if err != nil {
+		return nil, err
+	}
+	htmlSource, err := readTemplate(settings.HTMLTemplatePath, defaultHTMLReceipt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ReceiptService{repo: repo, stores: stores, settings: settings}
+
+	s.text, err = template.New("receipt").Funcs(s.textFuncs()).Parse(textSource)
+	if err != nil {
+		return nil, fmt.Errorf("parse text receipt template: %w", err)
+	}
+	s.html, err = htmltemplate.New("receipt").Funcs(htmltemplate.FuncMap(s.textFuncs())).Parse(htmlSource)
+	if err != nil {
+		return nil, fmt.Errorf("parse html receipt template: %w", err)
+	}
+
+	return s, nil
+}
+
+// Render returns the receipt of a transaction in the requested format
+// together with its content type.
+func (s *ReceiptService) Render(transactionID int, format string) ([]byte, string, error) {
+	transaction, err := s.repo.GetByID(transactionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := receiptData{Footer: s.settings.Footer, Transaction: transaction}
+	data.Store.Name = s.settings.StoreName
+	data.Store.Address = s.settings.StoreAddress
+	data.Store.Phone = s.settings.StorePhone
+	// Sales at stores other than the default carry that store's header.
+	if transaction.StoreID != 0 {
+		store, err := s.stores.GetByID(transaction.StoreID)
+		if err != nil {
+			return nil, "", err
+		}
+		data.Store.Name, data.Store.Address, data.Store.Phone = store.Name, store.Address, store.Phone
+	}
+
+	if format == ReceiptFormatHTML {
+		var buf bytes.Buffer
+		if err := s.html.Execute(&buf, data); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	}
+
+	var buf bytes.Buffer
+	if err := s.text.Execute(&buf, data); err != nil {
+		return nil, "", err
+	}
+
+	switch format {
+	case "", ReceiptFormatText:
+		return buf.Bytes(), "text/plain; charset=utf-8", nil
+	case ReceiptFormatESCPOS:
+		return escposReceipt(buf.Bytes()), "application/octet-stream", nil
+	case ReceiptFormatPDF:
+		return pdfReceipt(buf.String(), s.settings.Width), "application/pdf", nil
+	default:
+		return nil, "", fmt.Errorf("unknown receipt format %q, use text, escpos, pdf or html", format)
+	}
+}
+
+func (s *ReceiptService) textFuncs() template.FuncMap {
+	width := s.settings.Width
+	// Receipts print the time on the store's clock.
+	location := s.repo.BusinessDay().Location
+	return template.FuncMap{
+		"rupiah":    formatRupiah,
+		"upper":     strings.ToUpper,
+		"datetime":  func(t time.Time) string { return t.In(location).Format("02/01/2006 15:04") },
+		"unitPrice": func(d models.TransactionDetail) int { return d.Subtotal / max(d.Quantity, 1) },
+		"line":      func() string { return strings.Repeat("-", width) },
+		"center": func(text string) string {
+			if len(text) >= width {
+				return text
+			}
+			return strings.Repeat(" ", (width-len(text))/2) + text
+		},
+		"cols": func(left, right string) string {
+			gap := width - len(left) - len(right)
+			if gap < 1 {
+				gap = 1
+			}
+			return left + strings.Repeat(" ", gap) + right
+		},
+	}
+}
+
+func readTemplate(path, fallback string) (string, error) {
+	if path == "" {
+		return fallback, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read receipt template: %w", err)
+	}
+	return string(b), nil
+}
+
+// formatRupiah formats 15000 as "Rp15.000".
+func formatRupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+	return sign + "Rp" + b.String()
+}
+
+// escposReceipt wraps plain receipt text in the ESC/POS commands thermal
+// printers expect: reset, the text, a few blank lines and a partial cut.
+func escposReceipt(text []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x1b, 0x40})
+	buf.Write(bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n")))
+	buf.Write([]byte{0x1b, 0x64, 0x04})
+	buf.Write([]byte{0x1d, 0x56, 0x42, 0x00})
+	return buf.Bytes()
+}
+
+// pdfReceipt lays the receipt text out on a single narrow page in Courier,
+// sized like a roll receipt so it prints and emails the same way.
+func pdfReceipt(text string, width int) []byte {
+	const fontSize, leading, margin = 9, 11, 14
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	pageWidth := margin*2 + width*fontSize*6/10
+	pageHeight := margin*2 + len(lines)*leading
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", fontSize, leading, margin, pageHeight-margin-fontSize)
+	for _, line := range lines {
+		line = strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line)
+		fmt.Fprintf(&content, "(%s) Tj T*\n", line)
+	}
+	content.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
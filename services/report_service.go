@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"time"
+)
+
+type ReportService struct {
+	repo *repositories.ReportRepository
+}
+
+func NewReportService(repo *repositories.ReportRepository) *ReportService {
+	return &ReportService{repo: repo}
+}
+
+func (s *ReportService) Profit(from, to time.Time, groupBy string, storeID int) (*models.ProfitReport, error) {
+	if groupBy == "" {
+		groupBy = "product"
+	}
+	return s.repo.Profit(from, to, groupBy, storeID)
+}
+
+func (s *ReportService) InventoryValuation(asOf time.Time, storeID int) (*models.InventoryValuation, error) {
+	return s.repo.InventoryValuation(asOf, storeID)
+}
+
+// BusinessDay is the business day report dates are read in.
+func (s *ReportService) BusinessDay() models.BusinessDay {
+	return s.repo.BusinessDay()
+}
+
+func (s *ReportService) Sales(from, to time.Time, granularity string, storeID int) (*models.SalesReport, error) {
+	if granularity == "" {
+		granularity = "day"
+	}
+	if granularity == "hour" && to.Sub(from) > 93*24*time.Hour {
+		return nil, errors.New("hourly sales are limited to 93 days, pick a shorter period")
+	}
+	return s.repo.Sales(from, to, granularity, storeID)
+}
+
+func (s *ReportService) ProductRanking(filter models.RankingFilter) (*models.SalesRanking, error) {
+	if err := rankingDefaults(&filter); err != nil {
+		return nil, err
+	}
+	return s.repo.ProductRanking(filter)
+}
+
+func (s *ReportService) CategoryRanking(filter models.RankingFilter) (*models.SalesRanking, error) {
+	if err := rankingDefaults(&filter); err != nil {
+		return nil, err
+	}
+	return s.repo.CategoryRanking(filter)
+}
+
+func rankingDefaults(filter *models.RankingFilter) error {
+	if filter.SortBy == "" {
+		filter.SortBy = "revenue"
+	}
+	if filter.Order == "" {
+		filter.Order = "top"
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit < 0 {
+		return errors.New("limit cannot be negative")
+	}
+	return nil
+}
+
+// DeadStock lists stock that has not sold in the last days days.
+func (s *ReportService) DeadStock(days, storeID int) ([]models.DeadStockLine, error) {
+	if days <= 0 {
+		return nil, errors.New("days must be greater than zero")
+	}
+	return s.repo.DeadStock(time.Now().AddDate(0, 0, -days), storeID)
+}
+
+func (s *ReportService) StoreSales(from, to time.Time) ([]models.StoreSales, error) {
+	return s.repo.StoreSales(from, to)
+}
+
+func (s *ReportService) ExpiringLots(days int) ([]models.ProductLot, error) {
+	if days < 0 {
+		return nil, errors.New("days cannot be negative")
+	}
+	return s.repo.ExpiringLots(days)
+}
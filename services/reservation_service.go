@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"log"
+	"time"
+)
+
+const defaultReservationTTL = 15 * time.Minute
+
+type ReservationService struct {
+	repo *repositories.ReservationRepository
+}
+
+func NewReservationService(repo *repositories.ReservationRepository) *ReservationService {
+	return &ReservationService{repo: repo}
+}
+
+func (s *ReservationService) GetActive(reference string) ([]models.StockReservation, error) {
+	return s.repo.GetActive(reference)
+}
+
+func (s *ReservationService) Create(req models.ReservationRequest, actor models.Actor) ([]models.StockReservation, error) {
+	if req.TTLMinutes < 0 {
+		return nil, errors.New("ttl_minutes cannot be negative")
+	}
+	ttl := defaultReservationTTL
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	return s.repo.Create(req.Reference, req.Items, time.Now().Add(ttl), req.StoreID, actor)
+}
+
+func (s *ReservationService) Release(id int, actor models.Actor) error {
+	return s.repo.Release(id, actor)
+}
+
+// RunSweeper releases expired reservations every interval until ctx is
+// cancelled.
+func (s *ReservationService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := s.repo.ReleaseExpired()
+			if err != nil {
+				log.Println("Failed to release expired reservations: ", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("Released %d expired stock reservations", released)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"time"
+)
+
+type ShiftService struct {
+	repo *repositories.ShiftRepository
+}
+
+func NewShiftService(repo *repositories.ShiftRepository) *ShiftService {
+	return &ShiftService{repo: repo}
+}
+
+func (s *ShiftService) GetAll(status string, date *time.Time) ([]models.Shift, error) {
+	return s.repo.GetAll(status, date)
+}
+
+// BusinessDay is the business day shift dates are read in.
+func (s *ShiftService) BusinessDay() models.BusinessDay {
+	return s.repo.BusinessDay()
+}
+
+func (s *ShiftService) GetByID(id int) (*models.Shift, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *ShiftService) GetOpenByCashier(cashierID int) (*models.Shift, error) {
+	return s.repo.GetOpenByCashier(cashierID)
+}
+
+func (s *ShiftService) Open(cashierID int, registerID string, req models.OpenShiftRequest, actor models.Actor) (*models.Shift, error) {
+	if req.OpeningCash < 0 {
+		return nil, errors.New("opening_cash cannot be negative")
+	}
+	shift := &models.Shift{
+		CashierID:   cashierID,
+		RegisterID:  registerID,
+		OpeningCash: req.OpeningCash,
+		Note:        req.Note,
+	}
+	if err := s.repo.Open(shift, actor); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+func (s *ShiftService) AddCashMovement(m *models.CashMovement, actor models.Actor) error {
+	if m.Type != models.CashMovementIn && m.Type != models.CashMovementOut {
+		return errors.New("type must be cash_in or cash_out")
+	}
+	if m.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	return s.repo.AddCashMovement(m, actor)
+}
+
+func (s *ShiftService) Close(id int, req models.CloseShiftRequest, actor models.Actor) (*models.ZReport, error) {
+	if req.CountedCash < 0 {
+		return nil, errors.New("counted_cash cannot be negative")
+	}
+	return s.repo.Close(id, req, actor)
+}
+
+func (s *ShiftService) Report(id int) (*models.ZReport, error) {
+	return s.repo.Summary(id)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type StocktakeService struct {
+	repo *repositories.StocktakeRepository
+}
+
+func NewStocktakeService(repo *repositories.StocktakeRepository) *StocktakeService {
+	return &StocktakeService{repo: repo}
+}
+
+func (s *StocktakeService) GetAll(status string) ([]models.Stocktake, error) {
+	return s.repo.GetAll(status)
+}
+
+func (s *StocktakeService) GetByID(id int) (*models.Stocktake, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *StocktakeService) Create(stocktake *models.Stocktake, actor models.Actor) error {
+	return s.repo.Create(stocktake, actor)
+}
+
+func (s *StocktakeService) Count(id int, req models.StocktakeCountRequest, actor models.Actor) (*models.Stocktake, error) {
+	if req.Mode == "" {
+		req.Mode = models.StocktakeCountSet
+	}
+	if req.Mode != models.StocktakeCountSet && req.Mode != models.StocktakeCountAdd {
+		return nil, fmt.Errorf("invalid count mode %q, use set or add", req.Mode)
+	}
+	if len(req.Items) == 0 {
+		return nil, errors.New("count must contain at least one item")
+	}
+	for _, item := range req.Items {
+		if item.SKU == "" && item.ProductID == 0 {
+			return nil, errors.New("each count needs a product_id or sku")
+		}
+		if item.Quantity < 0 {
+			return nil, errors.New("counted quantity cannot be negative")
+		}
+	}
+	return s.repo.Count(id, req, actor)
+}
+
+func (s *StocktakeService) Approve(id int, actor models.Actor) (*models.Stocktake, error) {
+	return s.repo.Approve(id, actor)
+}
+
+func (s *StocktakeService) Cancel(id int, actor models.Actor) (*models.Stocktake, error) {
+	return s.repo.Cancel(id, actor)
+}
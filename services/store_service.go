@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type StoreService struct {
+	repo *repositories.StoreRepository
+}
+
+func NewStoreService(repo *repositories.StoreRepository) *StoreService {
+	return &StoreService{repo: repo}
+}
+
+func (s *StoreService) GetAll() ([]models.Store, error) {
+	return s.repo.GetAll()
+}
+
+func (s *StoreService) GetByID(id int) (*models.Store, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *StoreService) Create(store *models.Store, actor models.Actor) error {
+	if err := validateStore(store); err != nil {
+		return err
+	}
+	return s.repo.Create(store, actor)
+}
+
+func (s *StoreService) Update(id int, store *models.Store, actor models.Actor) error {
+	if err := validateStore(store); err != nil {
+		return err
+	}
+	store.ID = id
+	return s.repo.Update(store, actor)
+}
+
+func (s *StoreService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func validateStore(store *models.Store) error {
+	if store.Name == "" {
+		return errors.New("store name is required")
+	}
+	if store.Code == "" {
+		return errors.New("store code is required, it goes on receipt numbers")
+	}
+	return nil
+}
+
+func (s *StoreService) GetProducts(storeID int) ([]models.StoreProduct, error) {
+	if _, err := s.repo.GetByID(storeID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetProducts(storeID)
+}
+
+func (s *StoreService) SetPrices(storeID int, prices []models.StorePrice, actor models.Actor) error {
+	if len(prices) == 0 {
+		return errors.New("at least one price is required")
+	}
+	for _, p := range prices {
+		if p.Price != nil && *p.Price < 0 {
+			return errors.New("price cannot be negative")
+		}
+	}
+	return s.repo.SetPrices(storeID, prices, actor)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type SupplierService struct {
+	repo *repositories.SupplierRepository
+}
+
+func NewSupplierService(repo *repositories.SupplierRepository) *SupplierService {
+	return &SupplierService{repo: repo}
+}
+
+func (s *SupplierService) GetAll(name string) ([]models.Supplier, error) {
+	return s.repo.GetAll(name)
+}
+
+func (s *SupplierService) GetByID(id int) (*models.Supplier, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *SupplierService) Create(supplier *models.Supplier, actor models.Actor) error {
+	if supplier.Name == "" {
+		return errors.New("supplier name is required")
+	}
+	return s.repo.Create(supplier, actor)
+}
+
+func (s *SupplierService) Update(id int, supplier *models.Supplier, actor models.Actor) error {
+	if supplier.Name == "" {
+		return errors.New("supplier name is required")
+	}
+	supplier.ID = id
+	return s.repo.Update(supplier, actor)
+}
+
+func (s *SupplierService) Delete(id int, actor models.Actor) error {
+	return s.repo.Delete(id, actor)
+}
+
+func (s *SupplierService) GetProducts(supplierID int) ([]models.SupplierProduct, error) {
+	return s.repo.GetProducts(supplierID)
+}
+
+func (s *SupplierService) SetProducts(supplierID int, links []models.SupplierProduct, actor models.Actor) error {
+	if len(links) == 0 {
+		return errors.New("at least one product is required")
+	}
+	for _, link := range links {
+		if link.LastCost < 0 || link.MinStock < 0 || link.MaxStock < 0 {
+			return errors.New("last_cost, min_stock and max_stock cannot be negative")
+		}
+		if link.MaxStock != 0 && link.MaxStock < link.MinStock {
+			return errors.New("max_stock cannot be below min_stock")
+		}
+	}
+	return s.repo.SetProducts(supplierID, links, actor)
+}
+
+func (s *SupplierService) DeleteProduct(supplierID, productID, variantID int, actor models.Actor) error {
+	return s.repo.DeleteProduct(supplierID, productID, variantID, actor)
+}
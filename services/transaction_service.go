@@ -13,10 +13,35 @@ func NewTransactionService(repo *repositories.TransactionRepository) *Transactio
 	return &TransactionService{repo: repo}
 }
 
-func (s *TransactionService) Checkout(items []models.CheckoutItem) (*models.Transaction, error) {
-	return s.repo.CreateTransaction(items)
+func (s *TransactionService) Checkout(req models.CheckoutRequest) (*models.Transaction, error) {
+	return s.repo.CreateTransaction(req)
 }
 
-func (s *TransactionService) GetReportToday() (*models.Report, error) {
-	return s.repo.GetReportToday()
+func (s *TransactionService) Preview(req models.CheckoutRequest) (*models.Transaction, error) {
+	return s.repo.PreviewTransaction(req)
+}
+
+func (s *TransactionService) GetAll(filter models.TransactionFilter) ([]models.Transaction, error) {
+	return s.repo.GetAll(filter)
+}
+
+func (s *TransactionService) GetByID(id int) (*models.Transaction, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *TransactionService) GetByReceiptNumber(number string) (*models.Transaction, error) {
+	return s.repo.GetByReceiptNumber(number)
+}
+
+func (s *TransactionService) Refund(id int, actor models.Actor) (*models.Transaction, error) {
+	return s.repo.RefundTransaction(id, actor)
+}
+
+// BusinessDay is the business day transaction dates are read in.
+func (s *TransactionService) BusinessDay() models.BusinessDay {
+	return s.repo.BusinessDay()
+}
+
+func (s *TransactionService) GetReportToday(storeID int) (*models.Report, error) {
+	return s.repo.GetReportToday(storeID)
 }
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type TransferService struct {
+	repo *repositories.TransferRepository
+}
+
+func NewTransferService(repo *repositories.TransferRepository) *TransferService {
+	return &TransferService{repo: repo}
+}
+
+func (s *TransferService) GetAll(filter models.StockTransferFilter) ([]models.StockTransfer, error) {
+	return s.repo.GetAll(filter)
+}
+
+func (s *TransferService) GetByID(id int) (*models.StockTransfer, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *TransferService) Create(t *models.StockTransfer, actor models.Actor) error {
+	if t.FromStoreID == 0 || t.ToStoreID == 0 {
+		return errors.New("from_store_id and to_store_id are required")
+	}
+	if t.FromStoreID == t.ToStoreID {
+		return errors.New("a transfer must go to a different store")
+	}
+	if len(t.Items) == 0 {
+		return errors.New("transfer must contain at least one item")
+	}
+	seen := make(map[[2]int]bool, len(t.Items))
+	for _, item := range t.Items {
+		if item.Quantity <= 0 {
+			return errors.New("transfer quantity must be greater than zero")
+		}
+		key := [2]int{item.ProductID, item.VariantID}
+		if seen[key] {
+			return fmt.Errorf("product id %d variant id %d is listed twice", item.ProductID, item.VariantID)
+		}
+		seen[key] = true
+	}
+	return s.repo.Create(t, actor)
+}
+
+func (s *TransferService) Dispatch(id int, actor models.Actor) (*models.StockTransfer, error) {
+	return s.repo.Dispatch(id, actor)
+}
+
+func (s *TransferService) Receive(id int, receipt *models.TransferReceipt, actor models.Actor) (*models.StockTransfer, error) {
+	seen := make(map[[2]int]bool, len(receipt.Items))
+	for _, item := range receipt.Items {
+		if item.Quantity < 0 {
+			return nil, errors.New("received quantity cannot be negative")
+		}
+		key := [2]int{item.ProductID, item.VariantID}
+		if seen[key] {
+			return nil, fmt.Errorf("product id %d variant id %d is listed twice", item.ProductID, item.VariantID)
+		}
+		seen[key] = true
+	}
+	return s.repo.Receive(id, receipt, actor)
+}
+
+func (s *TransferService) Cancel(id int, actor models.Actor) (*models.StockTransfer, error) {
+	return s.repo.Cancel(id, actor)
+}
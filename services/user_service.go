@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+)
+
+type UserService struct {
+	repo *repositories.UserRepository
+}
+
+func NewUserService(repo *repositories.UserRepository) *UserService {
+	return &UserService{repo: repo}
+}
+
+func (s *UserService) GetAll() ([]models.User, error) {
+	return s.repo.GetAll()
+}
+
+func (s *UserService) GetByID(id int) (*models.User, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *UserService) Create(user *models.User, actor models.Actor) error {
+	if user.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if err := validateRole(user.Role); err != nil {
+		return err
+	}
+	hash, err := HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+	user.Active = true
+	return s.repo.Create(user, hash, actor)
+}
+
+// Update changes name, role, store and active flag, and the password when
+// one is given.
+func (s *UserService) Update(id int, user *models.User, actor models.Actor) error {
+	if err := validateRole(user.Role); err != nil {
+		return err
+	}
+	hash := ""
+	if user.Password != "" {
+		var err error
+		hash, err = HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = ""
+	}
+
+	user.ID = id
+	return s.repo.Update(user, hash, actor)
+}
+
+func validateRole(role string) error {
+	switch role {
+	case models.RoleOwner, models.RoleManager, models.RoleCashier:
+		return nil
+	}
+	return fmt.Errorf("invalid role %q, use owner, manager or cashier", role)
+}